@@ -0,0 +1,177 @@
+// Package bus moves messages between the services. Kafka carries them in
+// production; the in-process implementation lets the services run and be
+// exercised without a broker.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrClosed is returned by a Subscription's Fetch once it or its bus has
+// been closed
+var ErrClosed = errors.New("bus: closed")
+
+// Header is a message header. Keys are not unique.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is one record on a topic. Partition, Offset and HighWaterMark
+// are filled in on delivery and ignored on publish.
+type Message struct {
+	Topic         string
+	Partition     int
+	Offset        int64
+	HighWaterMark int64 // offset the partition's next message will get
+	Key           []byte
+	Value         []byte
+	Headers       []Header
+	Time          time.Time
+}
+
+// Header returns the value of the first header named key, compared case
+// insensitively, or "" when there is none
+func (m Message) Header(key string) string {
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Publisher appends messages to a topic. Messages with the same key go to
+// the same partition, so they are delivered in the order published.
+// Publish returns once the bus has durably accepted every message; when
+// only some were, the error is a WriteErrors.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msgs ...Message) error
+}
+
+// Subscription delivers a topic's messages to one member of a consumer
+// group. Members of the same group share the partitions between them.
+type Subscription interface {
+	// Fetch blocks until the next message arrives or ctx ends
+	Fetch(ctx context.Context) (Message, error)
+	// Ack records that every message of each given message's partition,
+	// up to and including it, has been processed. A group that restarts
+	// resumes after the last acked message.
+	Ack(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// PartitionOffsets is the span of offsets a partition holds: First is
+// its oldest message's, End the one its next message will get
+type PartitionOffsets struct {
+	Partition int
+	First     int64
+	End       int64
+}
+
+// Reader reads a topic's partitions by offset, outside any consumer
+// group, for tools that inspect or replay a topic
+type Reader interface {
+	// Offsets returns the span held by each of topic's partitions
+	Offsets(ctx context.Context, topic string) ([]PartitionOffsets, error)
+	// OffsetAt returns the offset of a partition's first message
+	// published at or after t, or its End when there is none
+	OffsetAt(ctx context.Context, topic string, partition int, t time.Time) (int64, error)
+	// Read calls fn with a partition's messages from start up to end,
+	// exclusive, in order, and returns fn's first error. Offsets the
+	// partition no longer holds are skipped, and it stops at the
+	// partition's End as of the call.
+	Read(ctx context.Context, topic string, partition int, start, end int64, fn func(Message) error) error
+}
+
+// Bus publishes messages, hands out subscriptions and reads topics
+type Bus interface {
+	Publisher
+	Reader
+	Subscribe(topic, group string, opts ...SubscribeOption) (Subscription, error)
+	Close() error
+}
+
+// Starting positions for a group that has acked nothing yet
+const (
+	StartNewest = "newest"
+	StartOldest = "oldest"
+)
+
+// Partition assignment strategies between the members of a group
+const (
+	BalanceRange      = "range"
+	BalanceRoundRobin = "roundrobin"
+)
+
+// SubscribeConfig holds the settings SubscribeOptions change
+type SubscribeConfig struct {
+	Start   string // StartNewest or StartOldest
+	Balance string // BalanceRange or BalanceRoundRobin
+}
+
+// SubscribeOption customises a subscription
+type SubscribeOption func(*SubscribeConfig)
+
+// StartAt sets where a group with no acked messages starts reading:
+// "newest" (also "latest", the default) or "oldest" (also "earliest")
+func StartAt(position string) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.Start = position
+	}
+}
+
+// WithBalance sets how partitions are spread over the group's members:
+// "range" (the default) or "roundrobin". "sticky" is rejected: the Kafka
+// client has no sticky assignor.
+func WithBalance(strategy string) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.Balance = strategy
+	}
+}
+
+func subscribeConfig(opts []SubscribeOption) (SubscribeConfig, error) {
+	var c SubscribeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	switch strings.ToLower(c.Start) {
+	case "", StartNewest, "latest":
+		c.Start = StartNewest
+	case StartOldest, "earliest":
+		c.Start = StartOldest
+	default:
+		return c, fmt.Errorf("unknown start position %q (want oldest or newest)", c.Start)
+	}
+
+	switch strings.ToLower(c.Balance) {
+	case "", BalanceRange:
+		c.Balance = BalanceRange
+	case BalanceRoundRobin:
+		c.Balance = BalanceRoundRobin
+	case "sticky":
+		return c, errors.New("sticky balancing is not supported; use range or roundrobin")
+	default:
+		return c, fmt.Errorf("unknown balance strategy %q (want range or roundrobin)", c.Balance)
+	}
+	return c, nil
+}
+
+// WriteErrors reports a Publish that failed for some messages only. It is
+// indexed like the published messages; nil entries were written.
+type WriteErrors []error
+
+func (e WriteErrors) Error() string {
+	failed := 0
+	for _, err := range e {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("bus: %d of %d messages failed", failed, len(e))
+}
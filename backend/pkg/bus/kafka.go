@@ -0,0 +1,250 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka is a Bus backed by a Kafka cluster
+type Kafka struct {
+	brokers []string
+	writer  *kafka.Writer
+	client  *kafka.Client // offset lookups
+}
+
+// Both implementations are Buses
+var (
+	_ Bus = (*Kafka)(nil)
+	_ Bus = (*Memory)(nil)
+)
+
+// NewKafka connects lazily: nothing is dialled until the first publish or
+// fetch
+func NewKafka(brokers []string) *Kafka {
+	return &Kafka{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Balancer:     &kafka.Hash{}, // keyless messages are spread round-robin
+			RequiredAcks: kafka.RequireAll,
+		},
+		client: &kafka.Client{Addr: kafka.TCP(brokers...)},
+	}
+}
+
+func (k *Kafka) Publish(ctx context.Context, topic string, msgs ...Message) error {
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = toKafka(m)
+		out[i].Topic = topic
+	}
+
+	err := k.writer.WriteMessages(ctx, out...)
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		return WriteErrors(writeErrs)
+	}
+	return err
+}
+
+func (k *Kafka) Subscribe(topic, group string, opts ...SubscribeOption) (Subscription, error) {
+	cfg, err := subscribeConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := kafka.LastOffset
+	if cfg.Start == StartOldest {
+		start = kafka.FirstOffset
+	}
+	var balancer kafka.GroupBalancer = kafka.RangeGroupBalancer{}
+	if cfg.Balance == BalanceRoundRobin {
+		balancer = kafka.RoundRobinGroupBalancer{}
+	}
+
+	return &kafkaSubscription{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:        k.brokers,
+			Topic:          topic,
+			GroupID:        group,
+			StartOffset:    start,
+			GroupBalancers: []kafka.GroupBalancer{balancer},
+		}),
+	}, nil
+}
+
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
+
+func (k *Kafka) Offsets(ctx context.Context, topic string) ([]PartitionOffsets, error) {
+	meta, err := k.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Topics) != 1 {
+		return nil, fmt.Errorf("bus: no metadata for topic %s", topic)
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, fmt.Errorf("bus: topic %s: %w", topic, err)
+	}
+
+	var requests []kafka.OffsetRequest
+	for _, p := range meta.Topics[0].Partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+	}
+	res, err := k.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []PartitionOffsets
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("bus: partition %d: %w", p.Partition, p.Error)
+		}
+		offsets = append(offsets, PartitionOffsets{Partition: p.Partition, First: p.FirstOffset, End: p.LastOffset})
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i].Partition < offsets[j].Partition
+	})
+	return offsets, nil
+}
+
+func (k *Kafka) OffsetAt(ctx context.Context, topic string, partition int, at time.Time) (int64, error) {
+	res, err := k.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{
+		topic: {kafka.TimeOffsetOf(partition, at), kafka.LastOffsetOf(partition)},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range res.Topics[topic] {
+		if p.Partition != partition {
+			continue
+		}
+		if p.Error != nil {
+			return 0, fmt.Errorf("bus: partition %d: %w", partition, p.Error)
+		}
+		// Kafka answers -1 when no message is that recent
+		for offset := range p.Offsets {
+			if offset >= 0 {
+				return offset, nil
+			}
+		}
+		return p.LastOffset, nil
+	}
+	return 0, fmt.Errorf("bus: topic %s has no partition %d", topic, partition)
+}
+
+func (k *Kafka) Read(ctx context.Context, topic string, partition int, start, end int64, fn func(Message) error) error {
+	offsets, err := k.Offsets(ctx, topic)
+	if err != nil {
+		return err
+	}
+	var held *PartitionOffsets
+	for i := range offsets {
+		if offsets[i].Partition == partition {
+			held = &offsets[i]
+		}
+	}
+	if held == nil {
+		return fmt.Errorf("bus: topic %s has no partition %d", topic, partition)
+	}
+	start = max(start, held.First)
+	end = min(end, held.End)
+	if end <= start {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   k.brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(start); err != nil {
+		return err
+	}
+
+	for {
+		m, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("bus: partition %d: %w", partition, err)
+		}
+		if m.Offset >= end {
+			return nil
+		}
+		if err := fn(fromKafka(m)); err != nil {
+			return err
+		}
+		if m.Offset+1 >= end {
+			return nil
+		}
+	}
+}
+
+// kafkaSubscription is one consumer group member
+type kafkaSubscription struct {
+	reader *kafka.Reader
+}
+
+func (s *kafkaSubscription) Fetch(ctx context.Context) (Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if errors.Is(err, io.EOF) {
+		return Message{}, ErrClosed
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	return fromKafka(m), nil
+}
+
+func (s *kafkaSubscription) Ack(ctx context.Context, msgs ...Message) error {
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+	}
+	return s.reader.CommitMessages(ctx, out...)
+}
+
+func (s *kafkaSubscription) Close() error {
+	return s.reader.Close()
+}
+
+func toKafka(m Message) kafka.Message {
+	headers := make([]kafka.Header, len(m.Headers))
+	for i, h := range m.Headers {
+		headers[i] = kafka.Header{Key: h.Key, Value: h.Value}
+	}
+	return kafka.Message{
+		Topic:   m.Topic,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+		Time:    m.Time,
+	}
+}
+
+func fromKafka(m kafka.Message) Message {
+	headers := make([]Header, len(m.Headers))
+	for i, h := range m.Headers {
+		headers[i] = Header{Key: h.Key, Value: h.Value}
+	}
+	return Message{
+		Topic:         m.Topic,
+		Partition:     m.Partition,
+		Offset:        m.Offset,
+		HighWaterMark: m.HighWaterMark,
+		Key:           m.Key,
+		Value:         m.Value,
+		Headers:       headers,
+		Time:          m.Time,
+	}
+}
@@ -0,0 +1,367 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryRetention is how many messages each in-memory partition keeps;
+// older ones are dropped, and a group that falls further behind skips them
+const memoryRetention = 100000
+
+// Memory is a Bus held in process memory, modelled on Kafka: topics are
+// split into partitions by key, consumer groups share the partitions
+// between their members and resume from their last ack. Nothing survives
+// the process.
+type Memory struct {
+	partitions int
+
+	mu     sync.Mutex
+	topics map[string]*memTopic
+	closed bool
+	done   chan struct{}
+}
+
+type memTopic struct {
+	partitions []*memPartition
+	groups     map[string]*memGroup
+	rr         int           // next partition for keyless messages
+	changed    chan struct{} // closed and replaced on every publish or rebalance
+}
+
+type memPartition struct {
+	base int64 // offset of log[0]
+	log  []Message
+}
+
+func (p *memPartition) end() int64 {
+	return p.base + int64(len(p.log))
+}
+
+type memGroup struct {
+	balance   string
+	members   []*memSubscription
+	owner     []*memSubscription // per partition
+	next      []int64            // next offset to deliver, per partition
+	committed []int64            // offset to resume from, per partition
+}
+
+// NewMemory creates an empty in-memory bus whose topics have the given
+// number of partitions
+func NewMemory(partitions int) *Memory {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &Memory{
+		partitions: partitions,
+		topics:     make(map[string]*memTopic),
+		done:       make(chan struct{}),
+	}
+}
+
+// topic returns the named topic, creating it on first use. m.mu must be
+// held.
+func (m *Memory) topic(name string) *memTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memTopic{
+			partitions: make([]*memPartition, m.partitions),
+			groups:     make(map[string]*memGroup),
+			changed:    make(chan struct{}),
+		}
+		for i := range t.partitions {
+			t.partitions[i] = &memPartition{}
+		}
+		m.topics[name] = t
+	}
+	return t
+}
+
+func (t *memTopic) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, msgs ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	t := m.topic(topic)
+	now := time.Now()
+	for _, msg := range msgs {
+		i := t.rr
+		if len(msg.Key) > 0 {
+			h := fnv.New32a()
+			h.Write(msg.Key)
+			i = int(h.Sum32() % uint32(len(t.partitions)))
+		} else {
+			t.rr = (t.rr + 1) % len(t.partitions)
+		}
+		p := t.partitions[i]
+
+		msg.Topic = topic
+		msg.Partition = i
+		msg.Offset = p.end()
+		msg.HighWaterMark = 0
+		if msg.Time.IsZero() {
+			msg.Time = now
+		}
+		p.log = append(p.log, msg)
+
+		if len(p.log) > memoryRetention {
+			drop := len(p.log) - memoryRetention
+			p.log = append([]Message(nil), p.log[drop:]...)
+			p.base += int64(drop)
+		}
+	}
+	t.notify()
+	return nil
+}
+
+func (m *Memory) Subscribe(topic, group string, opts ...SubscribeOption) (Subscription, error) {
+	cfg, err := subscribeConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	t := m.topic(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memGroup{
+			balance:   cfg.Balance,
+			owner:     make([]*memSubscription, len(t.partitions)),
+			next:      make([]int64, len(t.partitions)),
+			committed: make([]int64, len(t.partitions)),
+		}
+		for i, p := range t.partitions {
+			if cfg.Start == StartOldest {
+				g.committed[i] = p.base
+			} else {
+				g.committed[i] = p.end()
+			}
+		}
+		t.groups[group] = g
+	}
+
+	s := &memSubscription{bus: m, topic: t, group: g}
+	g.members = append(g.members, s)
+	g.rebalance()
+	t.notify()
+	return s, nil
+}
+
+// rebalance spreads the partitions over the members. A partition that
+// changes hands resumes from its last ack, as with Kafka, so messages the
+// previous owner fetched but never acked are delivered again.
+func (g *memGroup) rebalance() {
+	n := len(g.owner)
+	for i := 0; i < n; i++ {
+		var owner *memSubscription
+		if len(g.members) > 0 {
+			if g.balance == BalanceRoundRobin {
+				owner = g.members[i%len(g.members)]
+			} else {
+				// Contiguous ranges, the first members taking one extra
+				per, extra := n/len(g.members), n%len(g.members)
+				for m, start := 0, 0; m < len(g.members); m++ {
+					size := per
+					if m < extra {
+						size++
+					}
+					if i < start+size {
+						owner = g.members[m]
+						break
+					}
+					start += size
+				}
+			}
+		}
+		if owner != g.owner[i] {
+			g.owner[i] = owner
+			g.next[i] = g.committed[i]
+		}
+	}
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.done)
+	}
+	return nil
+}
+
+// memSubscription is one member of an in-memory consumer group
+type memSubscription struct {
+	bus    *Memory
+	topic  *memTopic
+	group  *memGroup
+	rr     int // partition to look at first on the next fetch
+	closed bool
+}
+
+func (s *memSubscription) Fetch(ctx context.Context) (Message, error) {
+	m := s.bus
+	for {
+		m.mu.Lock()
+		if s.closed || m.closed {
+			m.mu.Unlock()
+			return Message{}, ErrClosed
+		}
+
+		n := len(s.topic.partitions)
+		for j := 0; j < n; j++ {
+			i := (s.rr + j) % n
+			if s.group.owner[i] != s {
+				continue
+			}
+			p := s.topic.partitions[i]
+			if s.group.next[i] < p.base {
+				// Fell behind retention
+				s.group.next[i] = p.base
+			}
+			if s.group.next[i] < p.end() {
+				msg := p.log[s.group.next[i]-p.base]
+				msg.HighWaterMark = p.end()
+				s.group.next[i]++
+				s.rr = i + 1
+				m.mu.Unlock()
+				return msg, nil
+			}
+		}
+		changed := s.topic.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-m.done:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+func (s *memSubscription) Ack(ctx context.Context, msgs ...Message) error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	for _, msg := range msgs {
+		if msg.Partition < 0 || msg.Partition >= len(s.group.committed) {
+			continue
+		}
+		if msg.Offset+1 > s.group.committed[msg.Partition] {
+			s.group.committed[msg.Partition] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (s *memSubscription) Close() error {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	members := s.group.members[:0]
+	for _, member := range s.group.members {
+		if member != s {
+			members = append(members, member)
+		}
+	}
+	s.group.members = members
+	s.group.rebalance()
+	s.topic.notify()
+	return nil
+}
+
+func (m *Memory) Offsets(ctx context.Context, topic string) ([]PartitionOffsets, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	t := m.topic(topic)
+	offsets := make([]PartitionOffsets, len(t.partitions))
+	for i, p := range t.partitions {
+		offsets[i] = PartitionOffsets{Partition: i, First: p.base, End: p.end()}
+	}
+	return offsets, nil
+}
+
+func (m *Memory) OffsetAt(ctx context.Context, topic string, partition int, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, err := m.partition(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+
+	// Messages are stamped as they are published, so in time order
+	i := sort.Search(len(p.log), func(i int) bool {
+		return !p.log[i].Time.Before(at)
+	})
+	return p.base + int64(i), nil
+}
+
+func (m *Memory) Read(ctx context.Context, topic string, partition int, start, end int64, fn func(Message) error) error {
+	// Copied out so fn may publish, e.g. to the same topic
+	m.mu.Lock()
+	p, err := m.partition(topic, partition)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	start = max(start, p.base)
+	end = min(end, p.end())
+	var msgs []Message
+	if end > start {
+		msgs = append(msgs, p.log[start-p.base:end-p.base]...)
+	}
+	hwm := p.end()
+	m.mu.Unlock()
+
+	for _, msg := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msg.HighWaterMark = hwm
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partition returns one of topic's partitions. m.mu must be held.
+func (m *Memory) partition(topic string, partition int) (*memPartition, error) {
+	if m.closed {
+		return nil, ErrClosed
+	}
+	t := m.topic(topic)
+	if partition < 0 || partition >= len(t.partitions) {
+		return nil, fmt.Errorf("bus: topic %s has no partition %d", topic, partition)
+	}
+	return t.partitions[partition], nil
+}
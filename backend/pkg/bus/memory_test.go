@@ -0,0 +1,81 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryRead(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemory(1)
+	defer b.Close()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := b.Publish(ctx, "events", Message{Value: []byte{byte('a' + i)}, Time: start.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	offsets, err := b.Offsets(ctx, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 1 || offsets[0] != (PartitionOffsets{Partition: 0, First: 0, End: 5}) {
+		t.Fatalf("Offsets() = %+v, want partition 0 holding [0, 5)", offsets)
+	}
+
+	tests := []struct {
+		name       string
+		start, end int64
+		want       string
+	}{
+		{"all", 0, 5, "abcde"},
+		{"middle", 1, 3, "bc"},
+		{"past the end", 3, 100, "de"},
+		{"empty", 4, 4, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strings.Builder
+			err := b.Read(ctx, "events", 0, tt.start, tt.end, func(m Message) error {
+				got.Write(m.Value)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Read(%d, %d) = %q, want %q", tt.start, tt.end, got.String(), tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want int64
+	}{
+		{start.Add(-time.Hour), 0},
+		{start.Add(2 * time.Second), 2},
+		{start.Add(1500 * time.Millisecond), 2},
+		{start.Add(time.Hour), 5},
+	} {
+		if got, err := b.OffsetAt(ctx, "events", 0, tt.at); err != nil || got != tt.want {
+			t.Errorf("OffsetAt(%s) = %d, %v; want %d", tt.at.Sub(start), got, err, tt.want)
+		}
+	}
+
+	if err := b.Read(ctx, "events", 1, 0, 1, func(Message) error { return nil }); err == nil {
+		t.Error("Read of a missing partition succeeded")
+	}
+}
+
+func TestSubscribeRejectsSticky(t *testing.T) {
+	b := NewMemory(1)
+	defer b.Close()
+	if _, err := b.Subscribe("events", "group", WithBalance("sticky")); err == nil || !strings.Contains(err.Error(), "sticky") {
+		t.Errorf("Subscribe with sticky balancing: err = %v, want it rejected", err)
+	}
+}
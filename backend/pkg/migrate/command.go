@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// ErrUsage is returned by Command for arguments it can't make sense of
+var ErrUsage = errors.New("invalid migrate command")
+
+// Command runs the `migrate up|down|status` subcommand of a service:
+// applies or reverts migrations, reporting each through logf, or writes
+// the status table to out. Bad arguments fail with an error wrapping
+// ErrUsage.
+func Command(ctx context.Context, m *Migrator, args []string, out io.Writer, logf func(format string, args ...interface{})) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			logf("⬆️ Applied migration %03d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		if len(applied) == 0 {
+			logf("✅ Schema is up to date")
+		}
+
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		fs.SetOutput(out)
+		steps := fs.Int("steps", 1, "number of migrations to revert, newest first")
+		if err := fs.Parse(args[1:]); err != nil {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		reverted, err := m.Down(ctx, *steps)
+		for _, mig := range reverted {
+			logf("⬇️ Reverted migration %03d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("revert failed: %w", err)
+		}
+
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read migration status: %w", err)
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				applied += " (unknown to this build)"
+			}
+			fmt.Fprintf(w, "%03d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+
+	default:
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, args[0])
+	}
+	return nil
+}
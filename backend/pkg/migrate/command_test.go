@@ -0,0 +1,24 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCommandUsage(t *testing.T) {
+	logf := func(string, ...interface{}) {}
+	for _, args := range [][]string{
+		nil,
+		{"sideways"},
+		{"down", "-steps", "two"},
+		{"down", "-force"},
+	} {
+		// Bad arguments fail before the migrator is touched
+		err := Command(context.Background(), nil, args, io.Discard, logf)
+		if !errors.Is(err, ErrUsage) {
+			t.Errorf("Command(%q) = %v, want ErrUsage", args, err)
+		}
+	}
+}
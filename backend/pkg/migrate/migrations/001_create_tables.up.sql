@@ -1,7 +1,7 @@
 -- Contextify + Anomaly Detection Schema
 
 -- Stores raw context events (latency, status, queue length etc.)
-CREATE TABLE contexts (
+CREATE TABLE IF NOT EXISTS contexts (
     id SERIAL PRIMARY KEY,
     service TEXT NOT NULL,
     timestamp TIMESTAMP NOT NULL DEFAULT NOW(),
@@ -11,7 +11,7 @@ CREATE TABLE contexts (
 );
 
 -- Stores anomalies detected from rules (latency, error %, queue length)
-CREATE TABLE anomalies (
+CREATE TABLE IF NOT EXISTS anomalies (
     id SERIAL PRIMARY KEY,
     type TEXT NOT NULL,
     service TEXT NOT NULL,
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_anomalies_service_time;
+DROP INDEX IF EXISTS idx_contexts_trace_time;
+
+ALTER TABLE contexts DROP COLUMN IF EXISTS trace_id;
@@ -1,6 +1,6 @@
 -- Add trace_id to contexts
 ALTER TABLE contexts
-ADD COLUMN trace_id TEXT NOT NULL DEFAULT '';
+ADD COLUMN IF NOT EXISTS trace_id TEXT NOT NULL DEFAULT '';
 
 -- Index for fast lookups by trace_id + timestamp (newest first)
 CREATE INDEX IF NOT EXISTS idx_contexts_trace_time
@@ -0,0 +1 @@
+ALTER TABLE anomalies DROP COLUMN IF EXISTS trace_id;
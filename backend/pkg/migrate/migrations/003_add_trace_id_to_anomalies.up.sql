@@ -0,0 +1 @@
+ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS trace_id TEXT;
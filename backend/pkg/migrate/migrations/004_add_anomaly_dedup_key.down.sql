@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_anomalies_dedup_key;
+
+ALTER TABLE anomalies DROP COLUMN IF EXISTS published;
+ALTER TABLE anomalies DROP COLUMN IF EXISTS dedup_key;
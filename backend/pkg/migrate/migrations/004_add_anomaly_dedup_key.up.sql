@@ -0,0 +1,8 @@
+-- Idempotent anomaly writes: one row per source message and anomaly type
+ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS dedup_key TEXT;
+
+-- Set once the anomaly has been published to the anomalies topic
+ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS published BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_anomalies_dedup_key
+ON anomalies(dedup_key);
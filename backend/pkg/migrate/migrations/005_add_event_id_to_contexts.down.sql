@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_contexts_event_id;
+
+ALTER TABLE contexts DROP COLUMN IF EXISTS event_id;
@@ -0,0 +1,10 @@
+-- Stable event identity so redelivered events are stored once
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS event_id TEXT;
+
+-- Rows written before event IDs existed get one derived from their key
+UPDATE contexts SET event_id = 'legacy-' || id WHERE event_id IS NULL;
+
+ALTER TABLE contexts ALTER COLUMN event_id SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contexts_event_id
+ON contexts(event_id);
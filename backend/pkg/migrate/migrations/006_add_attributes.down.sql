@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_anomalies_attributes;
+DROP INDEX IF EXISTS idx_contexts_attributes;
+
+ALTER TABLE anomalies DROP COLUMN IF EXISTS attributes;
+ALTER TABLE contexts DROP COLUMN IF EXISTS attributes;
+ALTER TABLE contexts DROP COLUMN IF EXISTS message;
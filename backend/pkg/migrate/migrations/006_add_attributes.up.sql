@@ -0,0 +1,14 @@
+-- Free-form event fields (region, version, endpoint, host, user tier...)
+-- that the schema has no column for, plus the event message
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS message TEXT NOT NULL DEFAULT '';
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- Anomalies carry the attributes of the event that raised them
+ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- Containment (@>) lookups for ?attr.key=value filters
+CREATE INDEX IF NOT EXISTS idx_contexts_attributes
+ON contexts USING GIN (attributes jsonb_path_ops);
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_attributes
+ON anomalies USING GIN (attributes jsonb_path_ops);
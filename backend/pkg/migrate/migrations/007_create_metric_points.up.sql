@@ -0,0 +1,19 @@
+-- Prometheus samples received over remote write, linked to a service
+CREATE TABLE IF NOT EXISTS metric_points (
+    id BIGSERIAL PRIMARY KEY,
+    service TEXT NOT NULL,
+    name TEXT NOT NULL,
+    -- Canonical series identity: name{label="value",...}, labels sorted
+    series TEXT NOT NULL,
+    labels JSONB NOT NULL DEFAULT '{}',
+    value DOUBLE PRECISION NOT NULL,
+    timestamp TIMESTAMP NOT NULL
+);
+
+-- Remote write retries resend samples; store each one once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_points_series_time
+ON metric_points(series, timestamp);
+
+-- Latest value of a metric for a service
+CREATE INDEX IF NOT EXISTS idx_metric_points_service_name_time
+ON metric_points(service, name, timestamp DESC);
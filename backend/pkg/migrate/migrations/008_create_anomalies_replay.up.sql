@@ -0,0 +1,22 @@
+-- Scratch output of detector replays, kept apart from live anomalies so
+-- rule changes can be compared before they go live
+CREATE TABLE IF NOT EXISTS anomalies_replay (
+    id BIGSERIAL PRIMARY KEY,
+    replay_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    service TEXT NOT NULL,
+    trace_id TEXT,
+    latency_ms INT,
+    error_rate DOUBLE PRECISION,
+    queue_length INT,
+    timestamp TIMESTAMP NOT NULL,
+    dedup_key TEXT NOT NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_anomalies_replay_dedup
+ON anomalies_replay(replay_id, dedup_key);
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_replay_service_time
+ON anomalies_replay(replay_id, service, timestamp DESC);
@@ -0,0 +1 @@
+ALTER TABLE contexts DROP COLUMN IF EXISTS sample_rate;
@@ -0,0 +1,4 @@
+-- Probability each context row had of surviving head sampling. A row
+-- stands for 1/sample_rate events, so SUM(1 / sample_rate) estimates the
+-- real count.
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1;
@@ -0,0 +1,23 @@
+-- Restores the single-tenant indexes. Fails if two tenants stored the
+-- same series at the same time, which only one of them may keep.
+CREATE INDEX IF NOT EXISTS idx_contexts_trace_time
+ON contexts(trace_id, timestamp DESC);
+DROP INDEX IF EXISTS idx_contexts_tenant_trace_time;
+DROP INDEX IF EXISTS idx_contexts_tenant_service_time;
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_service_time
+ON anomalies(service, timestamp DESC);
+DROP INDEX IF EXISTS idx_anomalies_tenant_service_time;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_points_series_time
+ON metric_points(series, timestamp);
+DROP INDEX IF EXISTS idx_metric_points_tenant_series_time;
+
+CREATE INDEX IF NOT EXISTS idx_metric_points_service_name_time
+ON metric_points(service, name, timestamp DESC);
+DROP INDEX IF EXISTS idx_metric_points_tenant_service_name_time;
+
+ALTER TABLE metric_points DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE anomalies_replay DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE anomalies DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE contexts DROP COLUMN IF EXISTS tenant_id;
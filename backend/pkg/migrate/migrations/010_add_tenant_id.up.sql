@@ -0,0 +1,27 @@
+-- Tenant owning each row. Rows from before multi-tenancy, and every row of
+-- a single-tenant deployment, belong to 'default'.
+ALTER TABLE contexts ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE anomalies ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE anomalies_replay ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE metric_points ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT 'default';
+
+-- Every query is scoped to one tenant; lead the lookup indexes with it
+CREATE INDEX IF NOT EXISTS idx_contexts_tenant_trace_time
+ON contexts(tenant_id, trace_id, timestamp DESC);
+DROP INDEX IF EXISTS idx_contexts_trace_time;
+
+CREATE INDEX IF NOT EXISTS idx_contexts_tenant_service_time
+ON contexts(tenant_id, service, timestamp DESC);
+
+CREATE INDEX IF NOT EXISTS idx_anomalies_tenant_service_time
+ON anomalies(tenant_id, service, timestamp DESC);
+DROP INDEX IF EXISTS idx_anomalies_service_time;
+
+-- Two tenants may send a series of the same name
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_points_tenant_series_time
+ON metric_points(tenant_id, series, timestamp);
+DROP INDEX IF EXISTS idx_metric_points_series_time;
+
+CREATE INDEX IF NOT EXISTS idx_metric_points_tenant_service_name_time
+ON metric_points(tenant_id, service, name, timestamp DESC);
+DROP INDEX IF EXISTS idx_metric_points_service_name_time;
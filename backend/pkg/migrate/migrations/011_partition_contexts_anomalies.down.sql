@@ -0,0 +1,51 @@
+-- Back to plain tables. Fails if two contexts share an event ID, or two
+-- anomalies a dedup key, which the partitioned tables allowed.
+
+-- contexts
+ALTER TABLE contexts RENAME TO contexts_partitioned;
+CREATE TABLE contexts (LIKE contexts_partitioned INCLUDING DEFAULTS);
+INSERT INTO contexts SELECT * FROM contexts_partitioned;
+
+ALTER SEQUENCE contexts_id_seq OWNED BY NONE;
+DROP TABLE contexts_partitioned;
+ALTER SEQUENCE contexts_id_seq OWNED BY contexts.id;
+
+ALTER TABLE contexts ADD PRIMARY KEY (id);
+
+CREATE UNIQUE INDEX idx_contexts_event_id
+ON contexts(event_id);
+
+CREATE INDEX idx_contexts_tenant_trace_time
+ON contexts(tenant_id, trace_id, timestamp DESC);
+
+CREATE INDEX idx_contexts_tenant_service_time
+ON contexts(tenant_id, service, timestamp DESC);
+
+CREATE INDEX idx_contexts_attributes
+ON contexts USING GIN (attributes jsonb_path_ops);
+
+-- anomalies
+ALTER TABLE anomalies RENAME TO anomalies_partitioned;
+CREATE TABLE anomalies (LIKE anomalies_partitioned INCLUDING DEFAULTS);
+INSERT INTO anomalies SELECT * FROM anomalies_partitioned;
+
+ALTER SEQUENCE anomalies_id_seq OWNED BY NONE;
+DROP TABLE anomalies_partitioned;
+ALTER SEQUENCE anomalies_id_seq OWNED BY anomalies.id;
+
+ALTER TABLE anomalies ADD PRIMARY KEY (id);
+ALTER TABLE anomalies ADD COLUMN published BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE anomalies a SET published = k.published
+FROM anomaly_keys k WHERE k.dedup_key = a.dedup_key;
+DROP TABLE anomaly_keys;
+
+CREATE UNIQUE INDEX idx_anomalies_dedup_key
+ON anomalies(dedup_key);
+
+CREATE INDEX idx_anomalies_tenant_service_time
+ON anomalies(tenant_id, service, timestamp DESC);
+
+CREATE INDEX idx_anomalies_attributes
+ON anomalies USING GIN (attributes jsonb_path_ops);
+
+DROP FUNCTION IF EXISTS create_daily_partitions(TEXT, DATE, DATE);
@@ -0,0 +1,106 @@
+-- Contexts and anomalies split into daily range partitions on timestamp,
+-- so retention drops whole partitions instead of deleting rows, and recent
+-- lookups only touch recent partitions. Daily partitions are named
+-- <table>_pYYYYMMDD; rows outside all of them land in <table>_default.
+--
+-- Unique indexes of a partitioned table must include the partition key.
+-- Contexts are deduplicated on (event_id, timestamp): redeliveries share
+-- both, the timestamp coming from the event, or from the bus message when
+-- the event has none. Anomalies are stamped when persisted, so their dedup
+-- keys and publish state move to anomaly_keys.
+--
+-- Partitions hold UTC days. The session is pinned to UTC so CURRENT_DATE
+-- and the casts to date below follow it whatever the server TimeZone.
+SET LOCAL TIME ZONE 'UTC';
+
+-- Creates the missing daily partitions of parent from first_day through
+-- last_day and returns how many it created
+CREATE OR REPLACE FUNCTION create_daily_partitions(parent TEXT, first_day DATE, last_day DATE)
+RETURNS INT AS $$
+DECLARE
+    d DATE := first_day;
+    child TEXT;
+    created INT := 0;
+BEGIN
+    WHILE d <= last_day LOOP
+        child := parent || '_p' || to_char(d, 'YYYYMMDD');
+        IF to_regclass(child) IS NULL THEN
+            EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                child, parent, d, d + 1);
+            created := created + 1;
+        END IF;
+        d := d + 1;
+    END LOOP;
+    RETURN created;
+END
+$$ LANGUAGE plpgsql;
+
+-- contexts
+ALTER TABLE contexts RENAME TO contexts_unpartitioned;
+CREATE TABLE contexts (LIKE contexts_unpartitioned INCLUDING DEFAULTS)
+PARTITION BY RANGE (timestamp);
+CREATE TABLE contexts_default PARTITION OF contexts DEFAULT;
+
+SELECT create_daily_partitions('contexts',
+    LEAST(COALESCE((SELECT MIN(timestamp)::date FROM contexts_unpartitioned), CURRENT_DATE), CURRENT_DATE),
+    CURRENT_DATE + 3);
+
+INSERT INTO contexts SELECT * FROM contexts_unpartitioned;
+
+-- Keep the id sequence, owned by the old table until now
+ALTER SEQUENCE contexts_id_seq OWNED BY NONE;
+DROP TABLE contexts_unpartitioned;
+ALTER SEQUENCE contexts_id_seq OWNED BY contexts.id;
+
+ALTER TABLE contexts ADD PRIMARY KEY (id, timestamp);
+
+CREATE UNIQUE INDEX idx_contexts_event_id_time
+ON contexts(event_id, timestamp);
+
+CREATE INDEX idx_contexts_tenant_trace_time
+ON contexts(tenant_id, trace_id, timestamp DESC);
+
+CREATE INDEX idx_contexts_tenant_service_time
+ON contexts(tenant_id, service, timestamp DESC);
+
+CREATE INDEX idx_contexts_attributes
+ON contexts USING GIN (attributes jsonb_path_ops);
+
+-- anomaly dedup keys, one row per anomaly written with a key
+CREATE TABLE IF NOT EXISTS anomaly_keys (
+    dedup_key TEXT PRIMARY KEY,
+    published BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO anomaly_keys(dedup_key, published, created_at)
+SELECT dedup_key, published, timestamp FROM anomalies WHERE dedup_key IS NOT NULL
+ON CONFLICT (dedup_key) DO NOTHING;
+
+-- anomalies
+ALTER TABLE anomalies RENAME TO anomalies_unpartitioned;
+CREATE TABLE anomalies (LIKE anomalies_unpartitioned INCLUDING DEFAULTS)
+PARTITION BY RANGE (timestamp);
+CREATE TABLE anomalies_default PARTITION OF anomalies DEFAULT;
+
+SELECT create_daily_partitions('anomalies',
+    LEAST(COALESCE((SELECT MIN(timestamp)::date FROM anomalies_unpartitioned), CURRENT_DATE), CURRENT_DATE),
+    CURRENT_DATE + 3);
+
+INSERT INTO anomalies SELECT * FROM anomalies_unpartitioned;
+
+ALTER SEQUENCE anomalies_id_seq OWNED BY NONE;
+DROP TABLE anomalies_unpartitioned;
+ALTER SEQUENCE anomalies_id_seq OWNED BY anomalies.id;
+
+ALTER TABLE anomalies DROP COLUMN published;
+ALTER TABLE anomalies ADD PRIMARY KEY (id, timestamp);
+
+CREATE INDEX idx_anomalies_dedup_key
+ON anomalies(dedup_key);
+
+CREATE INDEX idx_anomalies_tenant_service_time
+ON anomalies(tenant_id, service, timestamp DESC);
+
+CREATE INDEX idx_anomalies_attributes
+ON anomalies USING GIN (attributes jsonb_path_ops);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS context_rollups_1h;
+DROP TABLE IF EXISTS context_rollups_1m;
@@ -0,0 +1,43 @@
+-- Per-service aggregates of contexts over 1-minute and 1-hour buckets,
+-- kept up to date by contextify's rollup job so long ranges can be charted
+-- without scanning contexts. count and error_count estimate real events
+-- from each row's sample_rate, as does latency_avg; the percentiles are
+-- over the rows stored.
+CREATE TABLE IF NOT EXISTS context_rollups_1m (
+    tenant_id TEXT NOT NULL,
+    service TEXT NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    count BIGINT NOT NULL,
+    error_count BIGINT NOT NULL,
+    latency_min DOUBLE PRECISION,
+    latency_avg DOUBLE PRECISION,
+    latency_max DOUBLE PRECISION,
+    latency_p50 DOUBLE PRECISION,
+    latency_p95 DOUBLE PRECISION,
+    latency_p99 DOUBLE PRECISION,
+    queue_length_max INT,
+    PRIMARY KEY (tenant_id, service, bucket)
+);
+
+-- Latest bucket rolled up, and retention
+CREATE INDEX IF NOT EXISTS idx_context_rollups_1m_bucket
+ON context_rollups_1m(bucket);
+
+CREATE TABLE IF NOT EXISTS context_rollups_1h (
+    tenant_id TEXT NOT NULL,
+    service TEXT NOT NULL,
+    bucket TIMESTAMP NOT NULL,
+    count BIGINT NOT NULL,
+    error_count BIGINT NOT NULL,
+    latency_min DOUBLE PRECISION,
+    latency_avg DOUBLE PRECISION,
+    latency_max DOUBLE PRECISION,
+    latency_p50 DOUBLE PRECISION,
+    latency_p95 DOUBLE PRECISION,
+    latency_p99 DOUBLE PRECISION,
+    queue_length_max INT,
+    PRIMARY KEY (tenant_id, service, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_context_rollups_1h_bucket
+ON context_rollups_1h(bucket);
@@ -0,0 +1,20 @@
+-- The function as migration 011 created it
+CREATE OR REPLACE FUNCTION create_daily_partitions(parent TEXT, first_day DATE, last_day DATE)
+RETURNS INT AS $$
+DECLARE
+    d DATE := first_day;
+    child TEXT;
+    created INT := 0;
+BEGIN
+    WHILE d <= last_day LOOP
+        child := parent || '_p' || to_char(d, 'YYYYMMDD');
+        IF to_regclass(child) IS NULL THEN
+            EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                child, parent, d, d + 1);
+            created := created + 1;
+        END IF;
+        d := d + 1;
+    END LOOP;
+    RETURN created;
+END
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,29 @@
+-- Rows for a day without a partition land in <table>_default, e.g. when
+-- the retention job didn't run ahead of midnight. Creating that day's
+-- partition afterwards fails while the default partition holds some of
+-- its rows, so they are now moved into the new partition, which is then
+-- attached. Attaching builds the partitioned indexes on it.
+CREATE OR REPLACE FUNCTION create_daily_partitions(parent TEXT, first_day DATE, last_day DATE)
+RETURNS INT AS $$
+DECLARE
+    d DATE := first_day;
+    child TEXT;
+    created INT := 0;
+BEGIN
+    WHILE d <= last_day LOOP
+        child := parent || '_p' || to_char(d, 'YYYYMMDD');
+        IF to_regclass(child) IS NULL THEN
+            EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS)', child, parent);
+            EXECUTE format(
+                'WITH moved AS (DELETE FROM %I WHERE timestamp >= %L AND timestamp < %L RETURNING *)
+                 INSERT INTO %I SELECT * FROM moved',
+                parent || '_default', d, d + 1, child);
+            EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (%L) TO (%L)',
+                parent, child, d, d + 1);
+            created := created + 1;
+        END IF;
+        d := d + 1;
+    END LOOP;
+    RETURN created;
+END
+$$ LANGUAGE plpgsql;
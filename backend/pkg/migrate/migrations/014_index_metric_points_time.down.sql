@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_metric_points_time;
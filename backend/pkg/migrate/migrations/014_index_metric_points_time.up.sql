@@ -0,0 +1,3 @@
+-- Retention deletes metric points older than a cutoff across all series
+CREATE INDEX IF NOT EXISTS idx_metric_points_time
+ON metric_points(timestamp);
@@ -0,0 +1,92 @@
+// Package pipeline holds the building blocks shared by the ingestion
+// consumers.
+package pipeline
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Dedup remembers recently seen event IDs so a redelivered event can be
+// dropped before it is processed again. Entries expire after the window
+// and the oldest are evicted once maxSize is reached.
+type Dedup struct {
+	mu      sync.Mutex
+	window  time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	order   *list.List // oldest first
+}
+
+type dedupEntry struct {
+	id   string
+	seen time.Time
+}
+
+func NewDedup(window time.Duration, maxSize int) *Dedup {
+	return &Dedup{
+		window:  window,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Seen reports whether id was recorded within the window, and records it
+// if not
+func (d *Dedup) Seen(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.expire(now)
+
+	if _, ok := d.entries[id]; ok {
+		return true
+	}
+
+	d.entries[id] = d.order.PushBack(dedupEntry{id: id, seen: now})
+	if d.maxSize > 0 && d.order.Len() > d.maxSize {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(dedupEntry).id)
+	}
+	return false
+}
+
+// Contains reports whether id was recorded within the window, without
+// recording it; record it with Seen once the event is handled
+func (d *Dedup) Contains(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire(time.Now())
+	_, ok := d.entries[id]
+	return ok
+}
+
+func (d *Dedup) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.order.Len()
+}
+
+func (d *Dedup) expire(now time.Time) {
+	for el := d.order.Front(); el != nil; el = d.order.Front() {
+		e := el.Value.(dedupEntry)
+		if now.Sub(e.seen) <= d.window {
+			return
+		}
+		d.order.Remove(el)
+		delete(d.entries, e.id)
+	}
+}
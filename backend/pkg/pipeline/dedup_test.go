@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	d := NewDedup(time.Minute, 2)
+
+	if d.Contains("a") {
+		t.Fatal("Contains(a) before it was seen")
+	}
+	if d.Seen("a") {
+		t.Fatal("Seen(a) = true on first sight")
+	}
+	if !d.Contains("a") || !d.Seen("a") {
+		t.Fatal("a not remembered")
+	}
+
+	// The oldest ID makes room once maxSize is reached
+	d.Seen("b")
+	d.Seen("c")
+	if d.Contains("a") {
+		t.Error("a kept past maxSize")
+	}
+	if d.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", d.Len())
+	}
+
+	// Events without an ID are never duplicates
+	if d.Seen("") || d.Seen("") || d.Contains("") {
+		t.Error("empty ID treated as seen")
+	}
+}
+
+func TestDedupExpires(t *testing.T) {
+	d := NewDedup(time.Millisecond, 0)
+	d.Seen("a")
+	time.Sleep(5 * time.Millisecond)
+	if d.Contains("a") {
+		t.Error("a kept past the window")
+	}
+	if d.Len() != 0 {
+		t.Errorf("Len() = %d after expiry, want 0", d.Len())
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 8, false},
+		{"16", 16, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"eight", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("WORKERS", tt.value)
+		got, err := EnvInt("WORKERS", 8)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("EnvInt with %q = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEnvDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Minute, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"0s", 0, true},
+		{"10", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DEDUP_WINDOW", tt.value)
+		got, err := EnvDuration("DEDUP_WINDOW", time.Minute)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("EnvDuration with %q = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSamplingFromEnv(t *testing.T) {
+	t.Setenv("SAMPLING_SLOW_MS", "500")
+	t.Setenv("SAMPLING_DEFAULT_RATE", "0.5")
+	t.Setenv("SAMPLING_POLICIES", "checkout=0.1,search=0.05:200")
+	t.Setenv("SAMPLING_TRACE_WINDOW", "")
+	c, err := SamplingFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Default != (SamplingPolicy{Rate: 0.5, SlowMs: 500}) || c.TraceWindow != 10*time.Minute {
+		t.Errorf("default %+v over %v", c.Default, c.TraceWindow)
+	}
+	if c.Policies["checkout"] != (SamplingPolicy{Rate: 0.1, SlowMs: 500}) || c.Policies["search"] != (SamplingPolicy{Rate: 0.05, SlowMs: 200}) {
+		t.Errorf("policies %+v", c.Policies)
+	}
+
+	for key, value := range map[string]string{
+		"SAMPLING_SLOW_MS":      "-1",
+		"SAMPLING_DEFAULT_RATE": "2",
+		"SAMPLING_POLICIES":     "checkout",
+		"SAMPLING_TRACE_WINDOW": "forever",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, err := SamplingFromEnv(); err == nil {
+				t.Errorf("SamplingFromEnv accepted %s=%q", key, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/sujal-lgtm/Contextify/backend/pkg/pipeline
+
+go 1.23.0
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"sort"
+	"sync"
+)
+
+// OffsetTracker follows messages of one partition that complete out of
+// order and reports how far the partition can safely be committed: the
+// highest offset below which every tracked message is done.
+type OffsetTracker struct {
+	mu      sync.Mutex
+	pending []trackedOffset // in Track order, which is offset order
+	last    int64           // highest committable offset, -1 if none
+	tracked int64           // highest tracked offset, -1 if none
+}
+
+type trackedOffset struct {
+	offset int64
+	done   bool
+}
+
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{last: -1, tracked: -1}
+}
+
+// Track registers an offset as in flight. Offsets must be tracked in
+// increasing order; Track reports false and ignores an offset at or below
+// one already tracked, e.g. after a rebalance rewound the partition, in
+// which case the caller should start a new tracker.
+func (t *OffsetTracker) Track(offset int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if offset <= t.tracked {
+		return false
+	}
+	t.tracked = offset
+	t.pending = append(t.pending, trackedOffset{offset: offset})
+	return true
+}
+
+// Done marks a tracked offset as fully processed
+func (t *OffsetTracker) Done(offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := sort.Search(len(t.pending), func(i int) bool { return t.pending[i].offset >= offset })
+	if i < len(t.pending) && t.pending[i].offset == offset {
+		t.pending[i].done = true
+	}
+
+	n := 0
+	for n < len(t.pending) && t.pending[n].done {
+		t.last = t.pending[n].offset
+		n++
+	}
+	t.pending = t.pending[n:]
+}
+
+// Committable returns the highest offset with nothing unfinished before
+// it, and false if no offset has completed yet
+func (t *OffsetTracker) Committable() (int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last, t.last >= 0
+}
+
+// InFlight returns the number of tracked offsets not yet committable
+func (t *OffsetTracker) InFlight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
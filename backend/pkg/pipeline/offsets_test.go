@@ -0,0 +1,54 @@
+package pipeline
+
+import "testing"
+
+func TestOffsetTracker(t *testing.T) {
+	tests := []struct {
+		name     string
+		track    []int64
+		done     []int64
+		want     int64
+		wantOK   bool
+		inFlight int
+	}{
+		{"nothing done", []int64{5, 6, 7}, nil, -1, false, 3},
+		{"in order", []int64{5, 6, 7}, []int64{5, 6}, 6, true, 1},
+		{"gap holds back", []int64{5, 6, 7}, []int64{6, 7}, -1, false, 3},
+		{"gap filled", []int64{5, 6, 7}, []int64{7, 6, 5}, 7, true, 0},
+		{"sparse offsets", []int64{10, 20, 30}, []int64{10, 30}, 10, true, 2},
+		{"untracked done ignored", []int64{5}, []int64{4, 9}, -1, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewOffsetTracker()
+			for _, o := range tt.track {
+				if !tr.Track(o) {
+					t.Fatalf("Track(%d) = false", o)
+				}
+			}
+			for _, o := range tt.done {
+				tr.Done(o)
+			}
+			got, ok := tr.Committable()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Committable() = %d, %v; want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if n := tr.InFlight(); n != tt.inFlight {
+				t.Errorf("InFlight() = %d, want %d", n, tt.inFlight)
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerRefusesRewind(t *testing.T) {
+	tr := NewOffsetTracker()
+	tr.Track(5)
+	for _, o := range []int64{5, 3} {
+		if tr.Track(o) {
+			t.Errorf("Track(%d) after 5 = true, want false", o)
+		}
+	}
+	if !tr.Track(6) {
+		t.Error("Track(6) after 5 = false")
+	}
+}
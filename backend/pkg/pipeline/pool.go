@@ -0,0 +1,122 @@
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Pool runs jobs on a fixed set of workers. Jobs submitted with the same
+// key always land on the same worker and run in submission order, so
+// per-key state can live on the worker without locking. Each worker has a
+// bounded queue; Submit blocks once it is full, which pushes back on
+// whoever is reading the source.
+type Pool struct {
+	queues []chan func(worker int)
+	wg     sync.WaitGroup
+}
+
+// PoolConfig configures a Pool
+type PoolConfig struct {
+	Workers   int
+	QueueSize int // jobs buffered per worker
+
+	// OnTick, if set, runs on every worker goroutine every TickEvery, in
+	// between jobs. Workers use it to flush time-based batches.
+	OnTick    func(worker int)
+	TickEvery time.Duration
+
+	// OnClose, if set, runs once on every worker goroutine after Close has
+	// drained its queue, in place of a last OnTick. Workers use it to
+	// flush what they still hold, however recent.
+	OnClose func(worker int)
+}
+
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+
+	p := &Pool{queues: make([]chan func(int), cfg.Workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan func(int), cfg.QueueSize)
+		p.wg.Add(1)
+		go p.run(i, cfg)
+	}
+	return p
+}
+
+// Workers returns the number of workers
+func (p *Pool) Workers() int {
+	return len(p.queues)
+}
+
+// WorkerFor returns the worker that runs jobs for key
+func (p *Pool) WorkerFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Submit queues job on the worker owning key, blocking while that
+// worker's queue is full. It returns ctx.Err() if ctx ends first.
+func (p *Pool) Submit(ctx context.Context, key string, job func(worker int)) error {
+	select {
+	case p.queues[p.WorkerFor(key)] <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of queued jobs across all workers
+func (p *Pool) InFlight() int {
+	n := 0
+	for _, q := range p.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// Close stops accepting jobs and waits for the queued ones, and OnClose,
+// to finish
+func (p *Pool) Close() {
+	for _, q := range p.queues {
+		close(q)
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) run(worker int, cfg PoolConfig) {
+	defer p.wg.Done()
+
+	var tick <-chan time.Time
+	if cfg.OnTick != nil && cfg.TickEvery > 0 {
+		ticker := time.NewTicker(cfg.TickEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	queue := p.queues[worker]
+	for {
+		select {
+		case job, ok := <-queue:
+			if !ok {
+				switch {
+				case cfg.OnClose != nil:
+					cfg.OnClose(worker)
+				case cfg.OnTick != nil:
+					cfg.OnTick(worker)
+				}
+				return
+			}
+			job(worker)
+		case <-tick:
+			cfg.OnTick(worker)
+		}
+	}
+}
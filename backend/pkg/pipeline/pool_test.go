@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestPoolRunsKeyInOrderAndClosesEachWorker(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	closed := make(map[int]int)
+
+	p := NewPool(PoolConfig{
+		Workers:   4,
+		QueueSize: 2,
+		OnClose: func(worker int) {
+			mu.Lock()
+			closed[worker]++
+			mu.Unlock()
+		},
+	})
+	keys := []string{"a", "b", "c", "d", "e"}
+	for i := 0; i < 100; i++ {
+		key, i := keys[i%len(keys)], i
+		if err := p.Submit(context.Background(), key, func(worker int) {
+			if worker != p.WorkerFor(key) {
+				t.Errorf("job for %s ran on worker %d, want %d", key, worker, p.WorkerFor(key))
+			}
+			mu.Lock()
+			seen[key] = append(seen[key], i)
+			mu.Unlock()
+		}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	p.Close()
+
+	for _, key := range keys {
+		got := seen[key]
+		if len(got) != 20 {
+			t.Errorf("%s ran %d jobs, want 20", key, len(got))
+		}
+		for j := 1; j < len(got); j++ {
+			if got[j] < got[j-1] {
+				t.Errorf("%s ran out of order: %v", key, got)
+				break
+			}
+		}
+	}
+	for w := 0; w < p.Workers(); w++ {
+		if closed[w] != 1 {
+			t.Errorf("OnClose ran %d times on worker %d, want 1", closed[w], w)
+		}
+	}
+}
+
+func TestPoolSubmitHonoursContext(t *testing.T) {
+	block := make(chan struct{})
+	p := NewPool(PoolConfig{Workers: 1, QueueSize: 1})
+	defer p.Close()
+	defer close(block)
+
+	p.Submit(context.Background(), "k", func(int) { <-block }) // running
+	p.Submit(context.Background(), "k", func(int) {})          // queued
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Submit(ctx, "k", func(int) {}); err != context.Canceled {
+		t.Errorf("Submit on a full queue = %v, want context.Canceled", err)
+	}
+}
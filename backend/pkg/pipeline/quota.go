@@ -0,0 +1,318 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaLimit caps how many events a service may send. Zero leaves that
+// side unlimited.
+type QuotaLimit struct {
+	PerSecond float64 `json:"per_second"`
+	PerDay    int64   `json:"per_day"`
+}
+
+// ParseQuotaLimits reads comma-separated "service=per_second[:per_day]"
+// entries, e.g.
+//
+//	checkout=200:5000000,search=50,batch=:1000000
+//
+// An empty side is unlimited.
+func ParseQuotaLimits(s string) (map[string]QuotaLimit, error) {
+	limits := make(map[string]QuotaLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		service, spec, ok := strings.Cut(entry, "=")
+		service = strings.TrimSpace(service)
+		if !ok || service == "" {
+			return nil, fmt.Errorf("quota %q: want service=per_second[:per_day]", entry)
+		}
+
+		var limit QuotaLimit
+		perSecond, perDay, _ := strings.Cut(spec, ":")
+		if v := strings.TrimSpace(perSecond); v != "" {
+			r, err := strconv.ParseFloat(v, 64)
+			if err != nil || r < 0 {
+				return nil, fmt.Errorf("quota %q: invalid events per second", entry)
+			}
+			limit.PerSecond = r
+		}
+		if v := strings.TrimSpace(perDay); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("quota %q: invalid events per day", entry)
+			}
+			limit.PerDay = n
+		}
+		limits[service] = limit
+	}
+	return limits, nil
+}
+
+// QuotaCounts counts a service's events by what its quota made of them
+type QuotaCounts struct {
+	Accepted int64 `json:"accepted"` // within the limits
+	Excess   int64 `json:"excess"`   // over a limit
+	Dropped  int64 `json:"dropped"`  // excess turned away
+	Sampled  int64 `json:"sampled"`  // excess let through anyway
+}
+
+func (c *QuotaCounts) add(accepted, sampled bool) {
+	switch {
+	case accepted:
+		c.Accepted++
+	case sampled:
+		c.Excess++
+		c.Sampled++
+	default:
+		c.Excess++
+		c.Dropped++
+	}
+}
+
+// remove takes back an event counted by add as accepted or sampled
+func (c *QuotaCounts) remove(accepted bool) {
+	if accepted {
+		c.Accepted--
+		return
+	}
+	c.Excess--
+	c.Sampled--
+}
+
+// QuotaUsage is one service's usage against its limits
+type QuotaUsage struct {
+	Tenant  string     `json:"tenant"`
+	Service string     `json:"service"`
+	Limit   QuotaLimit `json:"limit"`
+
+	// Events the limits have room for now; absent for an unlimited side
+	SecondAvailable *float64 `json:"per_second_available,omitempty"`
+	DayAvailable    *int64   `json:"per_day_available,omitempty"`
+
+	Day   string      `json:"day"` // UTC date Today counts
+	Today QuotaCounts `json:"today"`
+	Total QuotaCounts `json:"total"` // since startup
+}
+
+// tokenBucket holds up to capacity tokens, refilled at rate per second
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newTokenBucket(capacity, rate float64, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate, last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+type quotaKey struct {
+	tenant  string
+	service string
+}
+
+type quotaState struct {
+	limit  QuotaLimit
+	second *tokenBucket // nil when unlimited
+
+	date  string // UTC date today counts, and PerDay applies to
+	today QuotaCounts
+	total QuotaCounts
+	last  time.Time // when an event was last seen
+}
+
+// quotaIdle is how long a service must have sent nothing, and since before
+// the current UTC day, for Quotas to forget it
+const quotaIdle = time.Hour
+
+// Quotas enforces per-service ingestion limits, kept apart for each tenant
+// sending a service: a token bucket holding a second's worth of events,
+// refilled at PerSecond, and a count of the events accepted on the current
+// UTC day, capped at PerDay and reset at midnight UTC. Excess events are
+// dropped, or a fraction of them let through when excessRate is set,
+// chosen by trace like the Sampler so traces stay whole. Services idle
+// since before the current day are forgotten, totals and all. It is safe
+// for concurrent use.
+type Quotas struct {
+	limits     map[string]QuotaLimit
+	fallback   QuotaLimit
+	excessRate float64
+
+	mu       sync.Mutex
+	services map[quotaKey]*quotaState
+	swept    time.Time // when idle services were last evicted
+}
+
+// NewQuotas applies limits by service, whichever tenant sends it, and
+// fallback to every other service
+func NewQuotas(limits map[string]QuotaLimit, fallback QuotaLimit, excessRate float64) *Quotas {
+	return &Quotas{
+		limits:     limits,
+		fallback:   fallback,
+		excessRate: excessRate,
+		services:   make(map[quotaKey]*quotaState),
+	}
+}
+
+// QuotaGrant is what Allow charged one event, for Refund to give back
+type QuotaGrant struct {
+	key     quotaKey
+	date    string // UTC day charged
+	limited bool   // within the limits, rather than let through as excess
+}
+
+// Allow takes a token for one event from the tenant's service and reports
+// whether the event may be published. An event that then fails to publish
+// should have its grant refunded, so an outage doesn't spend the quota.
+func (q *Quotas) Allow(tenant, service, traceID, eventID string) (QuotaGrant, bool) {
+	return q.allow(tenant, service, traceID, eventID, time.Now())
+}
+
+func (q *Quotas) allow(tenant, service, traceID, eventID string, now time.Time) (QuotaGrant, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	date := now.UTC().Format("2006-01-02")
+	q.evict(now, date)
+
+	key := quotaKey{tenant, service}
+	st := q.state(key, now)
+	st.last = now
+	if date != st.date {
+		st.date = date
+		st.today = QuotaCounts{}
+	}
+
+	accepted := true
+	if st.second != nil {
+		st.second.refill(now)
+		accepted = st.second.tokens >= 1
+	}
+	if st.limit.PerDay > 0 && accepted {
+		accepted = st.today.Accepted < st.limit.PerDay
+	}
+	if accepted && st.second != nil {
+		st.second.tokens--
+	}
+
+	sampled := !accepted && q.excessRate > 0 && sampleKey(traceID, eventID) < q.excessRate
+	st.today.add(accepted, sampled)
+	st.total.add(accepted, sampled)
+	return QuotaGrant{key: key, date: date, limited: accepted}, accepted || sampled
+}
+
+// Refund gives back what Allow charged an event that was allowed but
+// never published. A grant from a day since over is past refunding.
+func (q *Quotas) Refund(g QuotaGrant) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	st, ok := q.services[g.key]
+	if !ok || st.date != g.date {
+		return
+	}
+	if g.limited && st.second != nil {
+		st.second.tokens = math.Min(st.second.capacity, st.second.tokens+1)
+	}
+	st.today.remove(g.limited)
+	st.total.remove(g.limited)
+}
+
+// state returns a tenant's service's quota state, creating it on first
+// use. q.mu must be held.
+func (q *Quotas) state(key quotaKey, now time.Time) *quotaState {
+	st, ok := q.services[key]
+	if ok {
+		return st
+	}
+
+	limit, ok := q.limits[key.service]
+	if !ok {
+		limit = q.fallback
+	}
+	st = &quotaState{limit: limit}
+	if limit.PerSecond > 0 {
+		// A second's worth of burst, but always room for one event
+		st.second = newTokenBucket(math.Max(limit.PerSecond, 1), limit.PerSecond, now)
+	}
+	q.services[key] = st
+	return st
+}
+
+// evict forgets the services that have sent nothing for quotaIdle and
+// nothing on date, checking at most once per quotaIdle. Their day counts
+// would start over anyway. q.mu must be held.
+func (q *Quotas) evict(now time.Time, date string) {
+	if now.Sub(q.swept) < quotaIdle {
+		return
+	}
+	q.swept = now
+	for key, st := range q.services {
+		if st.date != date && now.Sub(st.last) >= quotaIdle {
+			delete(q.services, key)
+		}
+	}
+}
+
+// Usage returns the usage of every service Quotas remembers, sorted by
+// tenant and service
+func (q *Quotas) Usage() []QuotaUsage {
+	return q.usage(time.Now())
+}
+
+func (q *Quotas) usage(now time.Time) []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	date := now.UTC().Format("2006-01-02")
+	usage := make([]QuotaUsage, 0, len(q.services))
+	for key, st := range q.services {
+		u := QuotaUsage{Tenant: key.tenant, Service: key.service, Limit: st.limit, Day: date, Total: st.total}
+		if st.date == date {
+			u.Today = st.today
+		}
+		if st.second != nil {
+			st.second.refill(now)
+			tokens := st.second.tokens
+			u.SecondAvailable = &tokens
+		}
+		if st.limit.PerDay > 0 {
+			left := st.limit.PerDay - u.Today.Accepted
+			u.DayAvailable = &left
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Tenant != usage[j].Tenant {
+			return usage[i].Tenant < usage[j].Tenant
+		}
+		return usage[i].Service < usage[j].Service
+	})
+	return usage
+}
+
+// Limits returns the configured limits by service, and the limit of every
+// other service
+func (q *Quotas) Limits() (map[string]QuotaLimit, QuotaLimit) {
+	limits := make(map[string]QuotaLimit, len(q.limits))
+	for service, limit := range q.limits {
+		limits[service] = limit
+	}
+	return limits, q.fallback
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQuotasPerDayResetsAtUTCMidnight(t *testing.T) {
+	q := NewQuotas(map[string]QuotaLimit{"checkout": {PerDay: 3}}, QuotaLimit{}, 0)
+	start := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+
+	// Spread over the hour before midnight, only PerDay get through
+	accepted := 0
+	for i := 0; i < 6; i++ {
+		if q.allowed("acme", "checkout", "", fmt.Sprint(i), start.Add(time.Duration(i)*10*time.Minute)) {
+			accepted++
+		}
+	}
+	if accepted != 3 {
+		t.Fatalf("accepted %d events in a day, want 3", accepted)
+	}
+
+	// A new UTC day starts the count over
+	if !q.allowed("acme", "checkout", "", "next", start.Add(time.Hour)) {
+		t.Fatal("event after midnight UTC refused")
+	}
+
+	usage := q.usage(start.Add(time.Hour))
+	if len(usage) != 1 {
+		t.Fatalf("usage for %d services, want 1", len(usage))
+	}
+	u := usage[0]
+	if u.Day != "2024-05-02" || u.Today.Accepted != 1 || u.DayAvailable == nil || *u.DayAvailable != 2 {
+		t.Errorf("usage = %+v, want 1 accepted and 2 left on 2024-05-02", u)
+	}
+	if u.Total.Accepted != 4 || u.Total.Dropped != 3 {
+		t.Errorf("total = %+v, want 4 accepted and 3 dropped", u.Total)
+	}
+}
+
+func TestQuotasPerSecond(t *testing.T) {
+	q := NewQuotas(nil, QuotaLimit{PerSecond: 2}, 0)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		after time.Duration
+		want  bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false}, // burst spent
+		{500 * time.Millisecond, true},
+		{0, false},
+		{2 * time.Second, true},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.after)
+		if got := q.allowed("acme", "search", "", fmt.Sprint(i), now); got != tt.want {
+			t.Errorf("event %d: allow = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestQuotasKeepTenantsApart(t *testing.T) {
+	q := NewQuotas(nil, QuotaLimit{PerDay: 1}, 0)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if !q.allowed("acme", "search", "", "a", now) || !q.allowed("globex", "search", "", "b", now) {
+		t.Fatal("first event of a tenant refused")
+	}
+	if q.allowed("acme", "search", "", "c", now) {
+		t.Error("acme went over its quota")
+	}
+}
+
+func TestQuotasExcessSampledByTrace(t *testing.T) {
+	q := NewQuotas(nil, QuotaLimit{PerDay: 1}, 0.5)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	q.allowed("acme", "search", "", "first", now)
+
+	// Every event of an excess trace gets the same answer
+	for i := 0; i < 20; i++ {
+		trace := fmt.Sprint("trace-", i)
+		want := q.allowed("acme", "search", trace, "a", now)
+		if got := q.allowed("acme", "search", trace, "b", now); got != want {
+			t.Errorf("%s: events sampled apart", trace)
+		}
+	}
+}
+
+func TestQuotasForgetIdleServices(t *testing.T) {
+	q := NewQuotas(nil, QuotaLimit{PerDay: 1}, 0)
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	q.allowed("acme", "old", "", "a", day)
+	q.allowed("acme", "busy", "", "b", day.Add(11*time.Hour))
+
+	// Still remembered the same day, however idle
+	if n := len(q.usage(day.Add(11 * time.Hour))); n != 2 {
+		t.Fatalf("usage for %d services before midnight, want 2", n)
+	}
+
+	q.allowed("acme", "busy", "", "c", day.Add(13*time.Hour))
+	usage := q.usage(day.Add(13 * time.Hour))
+	if len(usage) != 1 || usage[0].Service != "busy" {
+		t.Errorf("usage = %+v, want only busy", usage)
+	}
+}
+
+func (q *Quotas) allowed(tenant, service, traceID, eventID string, now time.Time) bool {
+	_, ok := q.allow(tenant, service, traceID, eventID, now)
+	return ok
+}
+
+func TestQuotasRefund(t *testing.T) {
+	q := NewQuotas(nil, QuotaLimit{PerSecond: 1, PerDay: 2}, 0)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// A failed publish gives its token and its place in the day back
+	for i := 0; i < 3; i++ {
+		grant, ok := q.allow("acme", "search", "", fmt.Sprint(i), now)
+		if !ok {
+			t.Fatalf("event %d refused after refunds", i)
+		}
+		q.Refund(grant)
+	}
+	u := q.usage(now)[0]
+	if u.Today.Accepted != 0 || *u.DayAvailable != 2 || *u.SecondAvailable != 1 {
+		t.Errorf("usage after refunds = %+v, want nothing spent", u)
+	}
+
+	// A grant from yesterday is past refunding
+	grant, _ := q.allow("acme", "search", "", "late", now)
+	tomorrow := now.Add(24 * time.Hour)
+	q.allowed("acme", "search", "", "next", tomorrow)
+	q.Refund(grant)
+	if u := q.usage(tomorrow)[0]; u.Today.Accepted != 1 {
+		t.Errorf("usage = %+v, want today's event still counted", u)
+	}
+}
@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplingPolicy says how much of a service's routine traffic to store.
+// Errors and requests at or above SlowMs are always kept.
+type SamplingPolicy struct {
+	Rate   float64 `json:"rate"`    // fraction of other events kept, 0..1
+	SlowMs int     `json:"slow_ms"` // 0 keeps no event for being slow
+}
+
+// ParseSamplingPolicies reads comma-separated "service=rate" entries,
+// optionally with a slow threshold: "service=rate:slow_ms", e.g.
+//
+//	checkout=0.1,search=0.05:500
+//
+// Entries without a threshold use defaultSlowMs.
+func ParseSamplingPolicies(s string, defaultSlowMs int) (map[string]SamplingPolicy, error) {
+	policies := make(map[string]SamplingPolicy)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		service, spec, ok := strings.Cut(entry, "=")
+		service = strings.TrimSpace(service)
+		if !ok || service == "" {
+			return nil, fmt.Errorf("sampling policy %q: want service=rate[:slow_ms]", entry)
+		}
+
+		policy := SamplingPolicy{SlowMs: defaultSlowMs}
+		rate, slow, hasSlow := strings.Cut(spec, ":")
+		r, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("sampling policy %q: rate must be between 0 and 1", entry)
+		}
+		policy.Rate = r
+		if hasSlow {
+			ms, err := strconv.Atoi(strings.TrimSpace(slow))
+			if err != nil || ms < 0 {
+				return nil, fmt.Errorf("sampling policy %q: invalid slow_ms", entry)
+			}
+			policy.SlowMs = ms
+		}
+		policies[service] = policy
+	}
+	return policies, nil
+}
+
+// SamplingConfig is the sampling policy of stored contexts, shared by
+// contextify and the anomaly service so both keep the same events
+type SamplingConfig struct {
+	Policies    map[string]SamplingPolicy // by service
+	Default     SamplingPolicy            // every other service
+	TraceWindow time.Duration             // how long a trace with a kept event keeps the rest of it
+}
+
+// SamplingFromEnv reads SAMPLING_POLICIES ("service=rate[:slow_ms],..."),
+// SAMPLING_DEFAULT_RATE, SAMPLING_SLOW_MS and SAMPLING_TRACE_WINDOW.
+// Errors and requests at or above SAMPLING_SLOW_MS are always kept; the
+// default keeps everything.
+func SamplingFromEnv() (SamplingConfig, error) {
+	c := SamplingConfig{Default: SamplingPolicy{Rate: 1, SlowMs: 300}}
+	if v := os.Getenv("SAMPLING_SLOW_MS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c, fmt.Errorf("invalid SAMPLING_SLOW_MS %q", v)
+		}
+		c.Default.SlowMs = n
+	}
+
+	if v := os.Getenv("SAMPLING_DEFAULT_RATE"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return c, fmt.Errorf("invalid SAMPLING_DEFAULT_RATE %q", v)
+		}
+		c.Default.Rate = r
+	}
+
+	var err error
+	if c.Policies, err = ParseSamplingPolicies(os.Getenv("SAMPLING_POLICIES"), c.Default.SlowMs); err != nil {
+		return c, fmt.Errorf("invalid SAMPLING_POLICIES: %w", err)
+	}
+
+	c.TraceWindow, err = EnvDuration("SAMPLING_TRACE_WINDOW", 10*time.Minute)
+	return c, err
+}
+
+// NewSampler is a Sampler applying the configured policies, remembering
+// at most maxTraces kept traces
+func (c SamplingConfig) NewSampler(maxTraces int) *Sampler {
+	return NewSampler(c.Policies, c.Default, c.TraceWindow, maxTraces)
+}
+
+// SamplingStats counts a service's sampling decisions since startup
+type SamplingStats struct {
+	Policy  SamplingPolicy `json:"policy"`
+	Seen    int64          `json:"seen"`
+	Kept    int64          `json:"kept"`
+	Dropped int64          `json:"dropped"`
+}
+
+// Sampler makes head sampling decisions for stored events. Decisions are
+// made per trace: a trace's ID hashes to the same verdict wherever it is
+// seen, and once any of its events is kept for being an error or slow,
+// the rest of the trace is kept too. Being a hash rather than a random
+// draw, every consumer applying the same policies agrees.
+type Sampler struct {
+	policies map[string]SamplingPolicy
+	fallback SamplingPolicy
+	traces   *Dedup // traces with a kept event
+
+	mu    sync.Mutex
+	stats map[string]*SamplingStats
+}
+
+// NewSampler applies policies by service and fallback to every other
+// service. Kept traces are remembered for traceWindow, at most maxTraces
+// of them.
+func NewSampler(policies map[string]SamplingPolicy, fallback SamplingPolicy, traceWindow time.Duration, maxTraces int) *Sampler {
+	return &Sampler{
+		policies: policies,
+		fallback: fallback,
+		traces:   NewDedup(traceWindow, maxTraces),
+		stats:    make(map[string]*SamplingStats),
+	}
+}
+
+// Sample decides whether to store an event. For a kept event it also
+// returns the probability it had of being kept, which is stored with it
+// so counts can be re-weighted (each row standing for 1/rate events).
+func (s *Sampler) Sample(service, traceID, eventID string, isError bool, latencyMs int) (bool, float64) {
+	policy, ok := s.policies[service]
+	if !ok {
+		policy = s.fallback
+	}
+
+	keep, rate := true, 1.0
+	switch {
+	case policy.Rate >= 1:
+	case isError || (policy.SlowMs > 0 && latencyMs >= policy.SlowMs):
+		// The rest of the trace comes along
+		s.traces.Seen(traceID)
+	case sampleKey(traceID, eventID) < policy.Rate:
+		rate = policy.Rate
+	case traceID != "" && s.traces.Contains(traceID):
+	default:
+		keep = false
+	}
+
+	s.mu.Lock()
+	st, ok := s.stats[service]
+	if !ok {
+		st = &SamplingStats{Policy: policy}
+		s.stats[service] = st
+	}
+	st.Seen++
+	if keep {
+		st.Kept++
+	} else {
+		st.Dropped++
+	}
+	s.mu.Unlock()
+
+	return keep, rate
+}
+
+// Stats returns the decisions made so far, by service
+func (s *Sampler) Stats() map[string]SamplingStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]SamplingStats, len(s.stats))
+	for service, st := range s.stats {
+		out[service] = *st
+	}
+	return out
+}
+
+// sampleKey maps the trace ID, or the event ID for events outside a
+// trace, uniformly onto [0, 1)
+func sampleKey(traceID, eventID string) float64 {
+	key := traceID
+	if key == "" {
+		key = eventID
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return float64(h.Sum64()>>11) / math.Exp2(53)
+}
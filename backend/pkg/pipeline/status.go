@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// rateWindow is how far back EventsPerSecond looks
+const rateWindow = 60
+
+// IngestStatus tracks consumer progress per partition: how far it has
+// processed, how far behind the partition head it is, and how fast it is
+// going. It is safe for concurrent use.
+type IngestStatus struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*PartitionStatus
+	started    time.Time
+
+	// per-second processed counts for the last rateWindow seconds
+	buckets [rateWindow]int64
+	bucketT [rateWindow]int64 // unix second each bucket belongs to
+}
+
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// PartitionStatus is the progress of one partition
+type PartitionStatus struct {
+	Topic              string    `json:"topic"`
+	Partition          int32     `json:"partition"`
+	LastOffset         int64     `json:"last_processed_offset"`
+	HighWaterMark      int64     `json:"high_water_mark"`
+	Lag                int64     `json:"lag"`
+	LastEventTimestamp int64     `json:"last_event_timestamp"` // unix millis
+	LastProcessedAt    time.Time `json:"last_processed_at"`
+	Processed          int64     `json:"processed"`
+}
+
+// IngestSnapshot is a point-in-time view of an IngestStatus
+type IngestSnapshot struct {
+	Partitions         []PartitionStatus `json:"partitions"`
+	TotalLag           int64             `json:"total_lag"`
+	Processed          int64             `json:"processed"`
+	EventsPerSecond    float64           `json:"events_per_second"`
+	LastEventTimestamp int64             `json:"last_event_timestamp"`
+	Uptime             string            `json:"uptime"`
+}
+
+func NewIngestStatus() *IngestStatus {
+	return &IngestStatus{
+		partitions: make(map[partitionKey]*PartitionStatus),
+		started:    time.Now(),
+	}
+}
+
+// Observe records a fetched message's offset and the partition head seen
+// alongside it
+func (s *IngestStatus) Observe(topic string, partition int32, offset, highWaterMark int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.partition(topic, partition)
+	if p.LastOffset < 0 {
+		// nothing processed yet; count lag from where we started reading
+		p.LastOffset = offset - 1
+	}
+	if highWaterMark > p.HighWaterMark {
+		p.HighWaterMark = highWaterMark
+	}
+	p.Lag = lag(p)
+}
+
+// Processed records that the message at offset is fully handled.
+// eventTimestamp is the event's own time in unix millis, 0 if unknown.
+func (s *IngestStatus) Processed(topic string, partition int32, offset, eventTimestamp int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	p := s.partition(topic, partition)
+	if offset > p.LastOffset {
+		p.LastOffset = offset
+	}
+	if eventTimestamp > p.LastEventTimestamp {
+		p.LastEventTimestamp = eventTimestamp
+	}
+	p.LastProcessedAt = now
+	p.Processed++
+	p.Lag = lag(p)
+
+	sec := now.Unix()
+	i := sec % rateWindow
+	if s.bucketT[i] != sec {
+		s.bucketT[i] = sec
+		s.buckets[i] = 0
+	}
+	s.buckets[i]++
+}
+
+// Snapshot returns the current status, partitions sorted by topic and number
+func (s *IngestStatus) Snapshot() IngestSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := IngestSnapshot{
+		Partitions: make([]PartitionStatus, 0, len(s.partitions)),
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+	}
+	for _, p := range s.partitions {
+		snap.Partitions = append(snap.Partitions, *p)
+		snap.TotalLag += p.Lag
+		snap.Processed += p.Processed
+		if p.LastEventTimestamp > snap.LastEventTimestamp {
+			snap.LastEventTimestamp = p.LastEventTimestamp
+		}
+	}
+	sort.Slice(snap.Partitions, func(i, j int) bool {
+		a, b := snap.Partitions[i], snap.Partitions[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+
+	// average over the last full window, excluding the current second
+	now := time.Now().Unix()
+	var count int64
+	for i := range s.buckets {
+		if t := s.bucketT[i]; t < now && now-t <= rateWindow {
+			count += s.buckets[i]
+		}
+	}
+	window := int64(rateWindow)
+	if elapsed := int64(time.Since(s.started).Seconds()); elapsed < window && elapsed > 0 {
+		window = elapsed
+	}
+	snap.EventsPerSecond = float64(count) / float64(window)
+
+	return snap
+}
+
+// WatchLag calls onLag every interval for each partition whose lag is
+// above threshold, until ctx ends
+func (s *IngestStatus) WatchLag(ctx context.Context, threshold int64, interval time.Duration, onLag func(PartitionStatus)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, p := range s.Snapshot().Partitions {
+				if p.Lag > threshold {
+					onLag(p)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *IngestStatus) partition(topic string, partition int32) *PartitionStatus {
+	key := partitionKey{topic, partition}
+	p, ok := s.partitions[key]
+	if !ok {
+		p = &PartitionStatus{Topic: topic, Partition: partition, LastOffset: -1}
+		s.partitions[key] = p
+	}
+	return p
+}
+
+// lag is the number of messages between the last processed one and the
+// partition head
+func lag(p *PartitionStatus) int64 {
+	if p.HighWaterMark == 0 {
+		return 0
+	}
+	if l := p.HighWaterMark - p.LastOffset - 1; l > 0 {
+		return l
+	}
+	return 0
+}
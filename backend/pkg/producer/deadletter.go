@@ -0,0 +1,68 @@
+package producer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+)
+
+// Headers added to every dead-lettered message. The original headers are
+// kept alongside them.
+const (
+	HeaderDLQReason          = "x-dlq-reason"
+	HeaderDLQSourceTopic     = "x-dlq-source-topic"
+	HeaderDLQSourcePartition = "x-dlq-source-partition"
+	HeaderDLQSourceOffset    = "x-dlq-source-offset"
+	HeaderDLQConsumer        = "x-dlq-consumer"
+	HeaderDLQFailedAt        = "x-dlq-failed-at"
+)
+
+// DeadLetter is a rejected message together with where it came from
+type DeadLetter struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []bus.Header
+	Consumer  string // consumer group that rejected it
+	Reason    string
+}
+
+// PublishDeadLetter forwards a rejected message unchanged, recording the
+// reason and source position in headers
+func (p *Publisher) PublishDeadLetter(ctx context.Context, dl DeadLetter) error {
+	headers := make([]bus.Header, 0, len(dl.Headers)+6)
+	for _, h := range dl.Headers {
+		if !IsDeadLetterHeader(h.Key) {
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers,
+		bus.Header{Key: HeaderDLQReason, Value: []byte(dl.Reason)},
+		bus.Header{Key: HeaderDLQSourceTopic, Value: []byte(dl.Topic)},
+		bus.Header{Key: HeaderDLQSourcePartition, Value: []byte(strconv.Itoa(dl.Partition))},
+		bus.Header{Key: HeaderDLQSourceOffset, Value: []byte(strconv.FormatInt(dl.Offset, 10))},
+		bus.Header{Key: HeaderDLQConsumer, Value: []byte(dl.Consumer)},
+		bus.Header{Key: HeaderDLQFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	return p.bus.Publish(ctx, p.topic, bus.Message{
+		Key:     dl.Key,
+		Value:   dl.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	})
+}
+
+// IsDeadLetterHeader reports whether a header was added by PublishDeadLetter
+func IsDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderDLQReason, HeaderDLQSourceTopic, HeaderDLQSourcePartition,
+		HeaderDLQSourceOffset, HeaderDLQConsumer, HeaderDLQFailedAt:
+		return true
+	}
+	return false
+}
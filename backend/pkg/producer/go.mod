@@ -2,10 +2,23 @@ module github.com/sujal-lgtm/Contextify/backend/pkg/producer
 
 go 1.23.0
 
-require github.com/segmentio/kafka-go v0.4.49
+require (
+	github.com/sujal-lgtm/Contextify/backend/pkg/bus v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/schema v0.0.0
+)
 
 require (
-	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/hamba/avro/v2 v2.27.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.10 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
-	golang.org/x/net v0.41.0 // indirect
+	github.com/segmentio/kafka-go v0.4.49 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/bus => ../bus
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/schema => ../schema
@@ -5,22 +5,47 @@ import (
 	"encoding/json"
 	"time"
 
-	"github.com/segmentio/kafka-go"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
 )
 
 type Publisher struct {
-	writer *kafka.Writer
+	bus      bus.Bus
+	topic    string
+	encoding string // content type used by Publish
+	owned    bool   // the bus was created by NewPublisher and is closed with it
 }
 
-func NewPublisher(brokers []string, topic string) *Publisher {
-	return &Publisher{
-		writer: &kafka.Writer{
-			Addr:         kafka.TCP(brokers...),
-			Topic:        topic,
-			Balancer:     &kafka.LeastBytes{},
-			RequiredAcks: kafka.RequireAll,
-		},
+// Option customises a Publisher
+type Option func(*Publisher)
+
+// WithEncoding makes Publish encode schema events as contentType, one of
+// the schema.ContentType* constants. The default is JSON.
+func WithEncoding(contentType string) Option {
+	return func(p *Publisher) {
+		p.encoding = contentType
+	}
+}
+
+// New publishes to topic over an existing bus, which the caller keeps
+// ownership of
+func New(b bus.Bus, topic string, opts ...Option) *Publisher {
+	p := &Publisher{
+		bus:      b,
+		topic:    topic,
+		encoding: schema.ContentTypeJSON,
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewPublisher publishes to topic on its own Kafka connection
+func NewPublisher(brokers []string, topic string, opts ...Option) *Publisher {
+	p := New(bus.NewKafka(brokers), topic, opts...)
+	p.owned = true
+	return p
 }
 
 func (p *Publisher) PublishEvent(ctx context.Context, key string, event interface{}) error {
@@ -29,15 +54,72 @@ func (p *Publisher) PublishEvent(ctx context.Context, key string, event interfac
 		return err
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(key),
-		Value: bytes,
-		Time:  time.Now(),
+	msg := bus.Message{
+		Key:     []byte(key),
+		Value:   bytes,
+		Headers: []bus.Header{{Key: schema.HeaderContentType, Value: []byte(schema.ContentTypeJSON)}},
+		Time:    time.Now(),
+	}
+
+	return p.bus.Publish(ctx, p.topic, msg)
+}
+
+// Publish sends a canonical schema event keyed by its service, naming its
+// tenant, if set, in the tenant header. Events without an ID get one
+// here, so writer retries carry the same ID and consumers can drop the
+// duplicates.
+func (p *Publisher) Publish(ctx context.Context, event schema.Event) error {
+	msg, err := p.message(event)
+	if err != nil {
+		return err
+	}
+	return p.bus.Publish(ctx, p.topic, msg)
+}
+
+// PublishBatch sends several canonical schema events in one write. When
+// only some fail the error is a bus.WriteErrors, indexed like events.
+func (p *Publisher) PublishBatch(ctx context.Context, events []schema.Event) error {
+	msgs := make([]bus.Message, len(events))
+	for i, event := range events {
+		msg, err := p.message(event)
+		if err != nil {
+			return err
+		}
+		msgs[i] = msg
+	}
+	return p.bus.Publish(ctx, p.topic, msgs...)
+}
+
+func (p *Publisher) message(event schema.Event) (bus.Message, error) {
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = schema.Version
+	}
+	if event.EventID == "" {
+		event.EventID = schema.NewEventID()
 	}
 
-	return p.writer.WriteMessages(ctx, msg)
+	bytes, err := schema.Encode(p.encoding, event)
+	if err != nil {
+		return bus.Message{}, err
+	}
+
+	headers := []bus.Header{{Key: schema.HeaderContentType, Value: []byte(p.encoding)}}
+	if event.TenantID != "" {
+		headers = append(headers, bus.Header{Key: schema.HeaderTenant, Value: []byte(event.TenantID)})
+	}
+
+	return bus.Message{
+		Key:     []byte(event.Service),
+		Value:   bytes,
+		Headers: headers,
+		Time:    time.Now(),
+	}, nil
 }
 
+// Close closes the bus only if NewPublisher created it
 func (p *Publisher) Close() error {
-	return p.writer.Close()
+	if p.owned {
+		return p.bus.Close()
+	}
+	return nil
 }
@@ -0,0 +1,188 @@
+package schema
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema/schemapb"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeaderContentType is the Kafka header naming a message's encoding.
+// Messages without it are JSON.
+const HeaderContentType = "content-type"
+
+// HeaderTenant is the Kafka header naming the tenant a message belongs
+// to. It takes precedence over the event's tenant_id field, and is the
+// only place Avro messages carry it: event.avsc has no tenant_id, as raw
+// Avro can't tell an old message from a new one.
+const HeaderTenant = "tenant-id"
+
+// HeaderEventID is the Kafka header naming the ID of an event whose
+// payload has none. Messages re-driven from a dead-letter topic carry the
+// ID the event was given at its original position, so consumers store it
+// under the same ID as before.
+const HeaderEventID = "event-id"
+
+// Supported encodings
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf" // schemapb.Event
+	ContentTypeAvro     = "application/avro"       // raw binary, event.avsc
+)
+
+//go:embed event.avsc
+var avroSchemaJSON string
+
+var avroSchema = avro.MustParse(avroSchemaJSON)
+
+// avroEvent mirrors event.avsc
+type avroEvent struct {
+	SchemaVersion int               `avro:"schema_version"`
+	EventID       string            `avro:"event_id"`
+	TraceID       string            `avro:"trace_id"`
+	Service       string            `avro:"service"`
+	Timestamp     time.Time         `avro:"timestamp"`
+	LatencyMs     int               `avro:"latency_ms"`
+	Status        string            `avro:"status"`
+	QueueLength   int               `avro:"queue_length"`
+	Message       string            `avro:"message"`
+	Extra         map[string]string `avro:"extra"`
+}
+
+// NormalizeContentType maps a content-type header to one of the supported
+// encodings, ignoring parameters and accepting common aliases
+func NormalizeContentType(contentType string) (string, error) {
+	if contentType == "" {
+		return ContentTypeJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("content-type %q: %w", contentType, err)
+	}
+
+	switch strings.ToLower(mediaType) {
+	case ContentTypeJSON, "text/json":
+		return ContentTypeJSON, nil
+	case ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return ContentTypeProtobuf, nil
+	case ContentTypeAvro, "avro/binary", "application/vnd.apache.avro+binary":
+		return ContentTypeAvro, nil
+	}
+	return "", fmt.Errorf("unsupported content-type %q", contentType)
+}
+
+// Decode parses an event in the given encoding. A missing timestamp
+// defaults to the current time.
+func Decode(contentType string, data []byte) (*Event, error) {
+	return DecodeAt(contentType, data, time.Now())
+}
+
+// DecodeAt is Decode giving an event without a timestamp the time
+// received instead. Consumers pass the bus message's time, which a
+// redelivery shares, so the event is stored under the same (event_id,
+// timestamp) every time.
+func DecodeAt(contentType string, data []byte, received time.Time) (*Event, error) {
+	if received.IsZero() {
+		received = time.Now()
+	}
+	ct, err := NormalizeContentType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ct {
+	case ContentTypeProtobuf:
+		var pe schemapb.Event
+		if err := proto.Unmarshal(data, &pe); err != nil {
+			return nil, fmt.Errorf("protobuf: %w", err)
+		}
+		return fromProto(&pe, received), nil
+
+	case ContentTypeAvro:
+		var ae avroEvent
+		if err := avro.Unmarshal(avroSchema, data, &ae); err != nil {
+			return nil, fmt.Errorf("avro: %w", err)
+		}
+		return fromAvro(&ae, received), nil
+	}
+	return parse(data, received)
+}
+
+// Encode serialises an event in the given encoding
+func Encode(contentType string, e Event) ([]byte, error) {
+	ct, err := NormalizeContentType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ct {
+	case ContentTypeProtobuf:
+		return proto.Marshal(e.ToProto())
+	case ContentTypeAvro:
+		return avro.Marshal(avroSchema, toAvro(e))
+	}
+	return json.Marshal(e)
+}
+
+// DecodeAndValidate decodes an event in the given encoding and applies the rules
+func (r Rules) DecodeAndValidate(contentType string, data []byte) (*Event, error) {
+	return r.DecodeAndValidateAt(contentType, data, time.Now())
+}
+
+// DecodeAndValidateAt is DecodeAndValidate with DecodeAt's handling of a
+// missing timestamp
+func (r Rules) DecodeAndValidateAt(contentType string, data []byte, received time.Time) (*Event, error) {
+	if r.MaxMessageBytes > 0 && len(data) > r.MaxMessageBytes {
+		return nil, &ValidationError{"message", fmt.Sprintf("%d bytes exceeds limit of %d", len(data), r.MaxMessageBytes)}
+	}
+	e, err := DecodeAt(contentType, data, received)
+	if err != nil {
+		return nil, &ValidationError{"message", err.Error()}
+	}
+	if err := r.Validate(e, len(data)); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func toAvro(e Event) *avroEvent {
+	ae := &avroEvent{
+		SchemaVersion: e.SchemaVersion,
+		EventID:       e.EventID,
+		TraceID:       e.TraceID,
+		Service:       e.Service,
+		Timestamp:     time.UnixMilli(e.Timestamp).UTC(),
+		LatencyMs:     e.LatencyMs,
+		Status:        e.Status,
+		QueueLength:   e.QueueLength,
+		Message:       e.Message,
+		Extra:         make(map[string]string, len(e.Extra)),
+	}
+	for k, v := range e.Extra {
+		ae.Extra[k] = string(v)
+	}
+	return ae
+}
+
+func fromAvro(ae *avroEvent, received time.Time) *Event {
+	// Same field set as the proto message; reuse its defaults, and its
+	// quoting of extras that aren't JSON
+	return fromProto(&schemapb.Event{
+		SchemaVersion: uint32(ae.SchemaVersion),
+		EventId:       ae.EventID,
+		TraceId:       ae.TraceID,
+		Service:       ae.Service,
+		Timestamp:     ae.Timestamp.UnixMilli(),
+		LatencyMs:     int32(ae.LatencyMs),
+		Status:        ae.Status,
+		QueueLength:   int32(ae.QueueLength),
+		Message:       ae.Message,
+		Extra:         ae.Extra,
+	}, received)
+}
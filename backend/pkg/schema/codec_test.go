@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+func TestDecodeAvroExtra(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		want  string
+	}{
+		{"json string", `"v"`, `"v"`},
+		{"json bool", `true`, `true`},
+		{"plain text", `v`, `"v"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := avro.Marshal(avroSchema, &avroEvent{
+				SchemaVersion: Version,
+				EventID:       "e1",
+				Service:       "checkout",
+				Timestamp:     time.UnixMilli(1700000000000).UTC(),
+				Extra:         map[string]string{"k": tt.extra},
+			})
+			if err != nil {
+				t.Fatalf("avro.Marshal: %v", err)
+			}
+
+			e, err := Decode(ContentTypeAvro, data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := string(e.Extra["k"]); got != tt.want {
+				t.Errorf("Extra[k] = %s, want %s", got, tt.want)
+			}
+			if _, err := json.Marshal(e); err != nil {
+				t.Errorf("json.Marshal: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	in := Event{
+		SchemaVersion: Version,
+		EventID:       "e1",
+		TraceID:       "t1",
+		Service:       "checkout",
+		Timestamp:     1700000000000,
+		LatencyMs:     120,
+		Status:        "error",
+		QueueLength:   3,
+		Message:       "boom",
+		Extra:         map[string]json.RawMessage{"region": json.RawMessage(`"eu-west-1"`)},
+	}
+	for _, ct := range []string{ContentTypeJSON, ContentTypeProtobuf, ContentTypeAvro} {
+		t.Run(ct, func(t *testing.T) {
+			data, err := Encode(ct, in)
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			out, err := Decode(ct, data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if out.EventID != in.EventID || out.Service != in.Service || out.Timestamp != in.Timestamp ||
+				out.LatencyMs != in.LatencyMs || out.Status != in.Status || out.Message != in.Message {
+				t.Errorf("Decode(Encode(e)) = %+v, want %+v", *out, in)
+			}
+			if got := out.Attributes(); got != `{"region":"eu-west-1"}` {
+				t.Errorf("Attributes() = %s", got)
+			}
+		})
+	}
+}
+
+func TestDecodeAtMissingTimestamp(t *testing.T) {
+	received := time.UnixMilli(1700000000000)
+	proto, err := Encode(ContentTypeProtobuf, Event{EventID: "e1", Service: "checkout"})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		want        int64
+	}{
+		{"json without timestamp", ContentTypeJSON, []byte(`{"service":"checkout"}`), received.UnixMilli()},
+		{"json with null timestamp", ContentTypeJSON, []byte(`{"service":"checkout","timestamp":null}`), received.UnixMilli()},
+		{"json with empty timestamp", ContentTypeJSON, []byte(`{"service":"checkout","timestamp":""}`), received.UnixMilli()},
+		{"json with timestamp", ContentTypeJSON, []byte(`{"service":"checkout","timestamp":42}`), 42},
+		{"protobuf without timestamp", ContentTypeProtobuf, proto, received.UnixMilli()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Redeliveries decode to the same timestamp
+			for i := 0; i < 2; i++ {
+				e, err := DecodeAt(tt.contentType, tt.data, received)
+				if err != nil {
+					t.Fatalf("DecodeAt: %v", err)
+				}
+				if e.Timestamp != tt.want {
+					t.Errorf("Timestamp = %d, want %d", e.Timestamp, tt.want)
+				}
+			}
+		})
+	}
+}
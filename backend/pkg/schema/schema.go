@@ -0,0 +1,407 @@
+// Package schema defines the canonical event published to contextify-events
+// and the parser every producer and consumer uses to read it.
+package schema
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema/schemapb"
+)
+
+// Version is the schema version stamped on events built by this package
+const Version = 1
+
+// DefaultTenant owns events that name no tenant, which is every event of a
+// single-tenant deployment
+const DefaultTenant = "default"
+
+// Event is the canonical context event. The wire layout is defined in
+// schemapb/event.proto; JSON keys match the proto field names.
+type Event struct {
+	SchemaVersion int    `json:"schema_version"`
+	EventID       string `json:"event_id"`
+	TraceID       string `json:"trace_id"`
+	Service       string `json:"service"`
+	TenantID      string `json:"tenant_id,omitempty"`
+	Timestamp     int64  `json:"timestamp"` // unix millis
+	LatencyMs     int    `json:"latency_ms"`
+	Status        string `json:"status"`
+	QueueLength   int    `json:"queue_length"`
+	Message       string `json:"message,omitempty"`
+
+	// Extra keeps any field the schema doesn't know about, verbatim, so it
+	// survives a parse/marshal round trip.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// knownFields are the keys consumed by Parse, including legacy aliases
+var knownFields = map[string]bool{
+	"schema_version": true,
+	"event_id":       true,
+	"trace_id":       true,
+	"service":        true,
+	"tenant_id":      true,
+	"timestamp":      true,
+	"latency_ms":     true,
+	"latency":        true, // legacy incident events
+	"status":         true,
+	"level":          true, // legacy producer events
+	"queue_length":   true,
+	"message":        true,
+}
+
+// NewEvent returns an event stamped with a fresh ID, the current schema
+// version and time
+func NewEvent(service string) Event {
+	return Event{
+		SchemaVersion: Version,
+		EventID:       NewEventID(),
+		Service:       service,
+		Timestamp:     time.Now().UnixMilli(),
+	}
+}
+
+// NewEventID returns a random 128-bit hex ID
+func NewEventID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// EnsureEventID gives an event the producer didn't supply an ID the one
+// named by the message's event ID header, else one derived from its
+// position in Kafka. The position is stable across redeliveries.
+func (e *Event) EnsureEventID(header, topic string, partition int, offset int64) {
+	switch {
+	case e.EventID != "":
+	case header != "":
+		e.EventID = header
+	default:
+		e.EventID = PositionEventID(topic, partition, offset)
+	}
+}
+
+// PositionEventID is the ID of an event without one at the given position
+// in Kafka
+func PositionEventID(topic string, partition int, offset int64) string {
+	return fmt.Sprintf("%s-%d-%d", topic, partition, offset)
+}
+
+// SetTenant settles which tenant the event belongs to: the one named by
+// the message's tenant header if any, else its own tenant_id field, else
+// DefaultTenant
+func (e *Event) SetTenant(header string) {
+	switch {
+	case header != "":
+		e.TenantID = header
+	case e.TenantID == "":
+		e.TenantID = DefaultTenant
+	}
+}
+
+// Parse decodes a JSON event. It accepts every format producers emit today:
+// timestamps as unix millis (number or string) or RFC3339 strings, and the
+// legacy "level" and "latency" keys in place of "status" and "latency_ms".
+// A missing timestamp defaults to the current time.
+func Parse(data []byte) (*Event, error) {
+	return parse(data, time.Now())
+}
+
+// parse is Parse with the time given to an event without a timestamp
+func parse(data []byte, fallback time.Time) (*Event, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	e := &Event{}
+	var err error
+
+	if e.SchemaVersion, err = intField(raw, "schema_version"); err != nil {
+		return nil, err
+	}
+	if e.SchemaVersion == 0 {
+		e.SchemaVersion = Version
+	}
+	if e.EventID, err = stringField(raw, "event_id"); err != nil {
+		return nil, err
+	}
+	if e.TraceID, err = stringField(raw, "trace_id"); err != nil {
+		return nil, err
+	}
+	if e.Service, err = stringField(raw, "service"); err != nil {
+		return nil, err
+	}
+	if e.TenantID, err = stringField(raw, "tenant_id"); err != nil {
+		return nil, err
+	}
+	if e.Message, err = stringField(raw, "message"); err != nil {
+		return nil, err
+	}
+	if e.QueueLength, err = intField(raw, "queue_length"); err != nil {
+		return nil, err
+	}
+
+	if e.Status, err = stringField(raw, "status"); err != nil {
+		return nil, err
+	}
+	if e.Status == "" {
+		if e.Status, err = stringField(raw, "level"); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.LatencyMs, err = intField(raw, "latency_ms"); err != nil {
+		return nil, err
+	}
+	if _, ok := raw["latency_ms"]; !ok {
+		if e.LatencyMs, err = intField(raw, "latency"); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.Timestamp, err = parseTimestamp(raw["timestamp"], fallback); err != nil {
+		return nil, err
+	}
+
+	for k, v := range raw {
+		if knownFields[k] {
+			continue
+		}
+		if e.Extra == nil {
+			e.Extra = make(map[string]json.RawMessage)
+		}
+		e.Extra[k] = v
+	}
+
+	return e, nil
+}
+
+// ParseTimestamp converts a raw JSON timestamp into unix millis. Numbers
+// and numeric strings are taken as millis, other strings as RFC3339.
+// A missing timestamp is the current time.
+func ParseTimestamp(raw json.RawMessage) (int64, error) {
+	return parseTimestamp(raw, time.Now())
+}
+
+func parseTimestamp(raw json.RawMessage, fallback time.Time) (int64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return fallback.UnixMilli(), nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return 0, fmt.Errorf("timestamp: %w", err)
+	}
+
+	switch ts := v.(type) {
+	case json.Number:
+		f, err := ts.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("timestamp: %w", err)
+		}
+		return int64(f), nil
+	case string:
+		if ts == "" {
+			return fallback.UnixMilli(), nil
+		}
+		if millis, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			return millis, nil
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return 0, fmt.Errorf("timestamp: %w", err)
+		}
+		return parsed.UnixMilli(), nil
+	}
+	return 0, fmt.Errorf("timestamp: unsupported type %T", v)
+}
+
+// MarshalJSON writes the schema fields followed by the preserved extras.
+// Extras never override schema fields.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type plain Event
+	base, err := json.Marshal(plain(e))
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Extra) == 0 {
+		return base, nil
+	}
+
+	keys := make([]string, 0, len(e.Extra))
+	for k := range e.Extra {
+		if !knownFields[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(base[:len(base)-1])
+	for _, k := range keys {
+		name, _ := json.Marshal(k)
+		buf.WriteByte(',')
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(e.Extra[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Attributes returns the preserved extras as a JSON object, "{}" when
+// there are none. It is what gets stored in the contexts.attributes column.
+func (e *Event) Attributes() string {
+	attrs := make(map[string]json.RawMessage, len(e.Extra))
+	for k, v := range e.Extra {
+		if !knownFields[k] {
+			attrs[k] = v
+		}
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		// Extras are taken verbatim from valid JSON, so this means a
+		// caller filled Extra by hand with something that isn't
+		return "{}"
+	}
+	return string(data)
+}
+
+// SetAttributes restores extras from a JSON object written by Attributes
+func (e *Event) SetAttributes(data []byte) error {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return fmt.Errorf("attributes: %w", err)
+	}
+	if len(attrs) == 0 {
+		e.Extra = nil
+		return nil
+	}
+	e.Extra = attrs
+	return nil
+}
+
+// UnmarshalJSON decodes with the same rules as Parse
+func (e *Event) UnmarshalJSON(data []byte) error {
+	parsed, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*e = *parsed
+	return nil
+}
+
+// ToProto converts the event to its wire message
+func (e Event) ToProto() *schemapb.Event {
+	pe := &schemapb.Event{
+		SchemaVersion: uint32(e.SchemaVersion),
+		EventId:       e.EventID,
+		TraceId:       e.TraceID,
+		Service:       e.Service,
+		TenantId:      e.TenantID,
+		Timestamp:     e.Timestamp,
+		LatencyMs:     int32(e.LatencyMs),
+		Status:        e.Status,
+		QueueLength:   int32(e.QueueLength),
+		Message:       e.Message,
+	}
+	if len(e.Extra) > 0 {
+		pe.Extra = make(map[string]string, len(e.Extra))
+		for k, v := range e.Extra {
+			pe.Extra[k] = string(v)
+		}
+	}
+	return pe
+}
+
+// FromProto converts a wire message back into an event. A missing
+// timestamp defaults to the current time.
+func FromProto(pe *schemapb.Event) *Event {
+	return fromProto(pe, time.Now())
+}
+
+// fromProto is FromProto with the time given to an event without a
+// timestamp
+func fromProto(pe *schemapb.Event, fallback time.Time) *Event {
+	e := &Event{
+		SchemaVersion: int(pe.GetSchemaVersion()),
+		EventID:       pe.GetEventId(),
+		TraceID:       pe.GetTraceId(),
+		Service:       pe.GetService(),
+		TenantID:      pe.GetTenantId(),
+		Timestamp:     pe.GetTimestamp(),
+		LatencyMs:     int(pe.GetLatencyMs()),
+		Status:        pe.GetStatus(),
+		QueueLength:   int(pe.GetQueueLength()),
+		Message:       pe.GetMessage(),
+	}
+	if e.SchemaVersion == 0 {
+		e.SchemaVersion = Version
+	}
+	if e.Timestamp == 0 {
+		e.Timestamp = fallback.UnixMilli()
+	}
+	for k, v := range pe.GetExtra() {
+		if e.Extra == nil {
+			e.Extra = make(map[string]json.RawMessage)
+		}
+		e.Extra[k] = extraValue(v)
+	}
+	return e
+}
+
+// extraValue is the Extra entry for a proto or Avro extra, which should
+// hold the value's JSON encoding. Clients often send plain text instead,
+// e.g. eu-west-1 rather than "eu-west-1"; such values are kept as JSON
+// strings, as Extra must stay valid JSON for the event to marshal.
+func extraValue(v string) json.RawMessage {
+	if json.Valid([]byte(v)) {
+		return json.RawMessage(v)
+	}
+	quoted, _ := json.Marshal(v)
+	return quoted
+}
+
+func stringField(raw map[string]json.RawMessage, key string) (string, error) {
+	v, ok := raw[key]
+	if !ok || string(v) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return "", fmt.Errorf("%s: expected string", key)
+	}
+	return s, nil
+}
+
+func intField(raw map[string]json.RawMessage, key string) (int, error) {
+	v, ok := raw[key]
+	if !ok || string(v) == "null" {
+		return 0, nil
+	}
+	var f float64
+	if err := json.Unmarshal(v, &f); err != nil {
+		// numeric strings are accepted as well
+		var s string
+		if json.Unmarshal(v, &s) != nil {
+			return 0, fmt.Errorf("%s: expected number", key)
+		}
+		if f, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, fmt.Errorf("%s: expected number", key)
+		}
+	}
+	return int(f), nil
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema/schemapb"
+)
+
+func TestFromProtoExtra(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		want  string // JSON value kept in Extra
+	}{
+		{"json string", `"eu-west-1"`, `"eu-west-1"`},
+		{"json number", `2`, `2`},
+		{"json object", `{"a":1}`, `{"a":1}`},
+		{"plain text", `eu-west-1`, `"eu-west-1"`},
+		{"plain text with quote", `say "hi`, `"say \"hi"`},
+		{"empty", ``, `""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := FromProto(&schemapb.Event{Service: "checkout", Timestamp: 1, Extra: map[string]string{"region": tt.extra}})
+
+			if got := string(e.Extra["region"]); got != tt.want {
+				t.Errorf("Extra[region] = %s, want %s", got, tt.want)
+			}
+			if _, err := json.Marshal(e); err != nil {
+				t.Errorf("json.Marshal: %v", err)
+			}
+			if got, want := e.Attributes(), `{"region":`+tt.want+`}`; got != want {
+				t.Errorf("Attributes() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestEnsureEventID(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		header string
+		want   string
+	}{
+		{"own ID", "e1", "events-0-7", "e1"},
+		{"header", "", "events-0-7", "events-0-7"},
+		{"position", "", "", "events-1-42"},
+	}
+	for _, tt := range tests {
+		e := Event{EventID: tt.id}
+		e.EnsureEventID(tt.header, "events", 1, 42)
+		if e.EventID != tt.want {
+			t.Errorf("%s: EventID = %q, want %q", tt.name, e.EventID, tt.want)
+		}
+	}
+}
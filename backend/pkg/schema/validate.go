@@ -0,0 +1,178 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules configures which events Validate accepts. Zero limits are not enforced.
+type Rules struct {
+	RequiredFields  []string      // schema fields that must be non-empty
+	MaxMessageBytes int           // size of the encoded message
+	MaxFieldLength  int           // length of any string field
+	MaxExtraFields  int           // number of non-schema fields
+	MaxLatencyMs    int           // latency_ms must be in [0, MaxLatencyMs]
+	MaxQueueLength  int           // queue_length must be in [0, MaxQueueLength]
+	MaxAge          time.Duration // how far in the past a timestamp may be
+	MaxClockSkew    time.Duration // how far in the future a timestamp may be
+}
+
+// ValidationError reports the field that failed and why
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// DefaultRules returns the rules used when nothing is configured
+func DefaultRules() Rules {
+	return Rules{
+		RequiredFields:  []string{"service"},
+		MaxMessageBytes: 64 * 1024,
+		MaxFieldLength:  1024,
+		MaxExtraFields:  64,
+		MaxLatencyMs:    int(time.Hour / time.Millisecond),
+		MaxQueueLength:  1_000_000,
+		MaxAge:          7 * 24 * time.Hour,
+		MaxClockSkew:    5 * time.Minute,
+	}
+}
+
+// RulesFromEnv starts from DefaultRules and applies any VALIDATION_* overrides
+func RulesFromEnv() (Rules, error) {
+	r := DefaultRules()
+
+	if v := os.Getenv("VALIDATION_REQUIRED_FIELDS"); v != "" {
+		r.RequiredFields = nil
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				r.RequiredFields = append(r.RequiredFields, f)
+			}
+		}
+	}
+
+	ints := map[string]*int{
+		"VALIDATION_MAX_MESSAGE_BYTES": &r.MaxMessageBytes,
+		"VALIDATION_MAX_FIELD_LENGTH":  &r.MaxFieldLength,
+		"VALIDATION_MAX_EXTRA_FIELDS":  &r.MaxExtraFields,
+		"VALIDATION_MAX_LATENCY_MS":    &r.MaxLatencyMs,
+		"VALIDATION_MAX_QUEUE_LENGTH":  &r.MaxQueueLength,
+	}
+	for key, dst := range ints {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return r, fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"VALIDATION_MAX_AGE":        &r.MaxAge,
+		"VALIDATION_MAX_CLOCK_SKEW": &r.MaxClockSkew,
+	}
+	for key, dst := range durations {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return r, fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = d
+		}
+	}
+
+	for _, f := range r.RequiredFields {
+		if _, ok := fieldValue(&Event{}, f); !ok {
+			return r, fmt.Errorf("VALIDATION_REQUIRED_FIELDS: unknown field %q", f)
+		}
+	}
+	return r, nil
+}
+
+// Validate checks a parsed event against the rules. size is the length of
+// the encoded message the event was parsed from.
+func (r Rules) Validate(e *Event, size int) error {
+	if r.MaxMessageBytes > 0 && size > r.MaxMessageBytes {
+		return &ValidationError{"message", fmt.Sprintf("%d bytes exceeds limit of %d", size, r.MaxMessageBytes)}
+	}
+
+	for _, f := range r.RequiredFields {
+		if v, ok := fieldValue(e, f); ok && v == "" {
+			return &ValidationError{f, "required"}
+		}
+	}
+
+	if r.MaxFieldLength > 0 {
+		for _, f := range []string{"event_id", "trace_id", "service", "tenant_id", "status", "message"} {
+			if v, _ := fieldValue(e, f); len(v) > r.MaxFieldLength {
+				return &ValidationError{f, fmt.Sprintf("longer than %d characters", r.MaxFieldLength)}
+			}
+		}
+	}
+
+	if r.MaxExtraFields > 0 && len(e.Extra) > r.MaxExtraFields {
+		return &ValidationError{"extra", fmt.Sprintf("%d unknown fields exceeds limit of %d", len(e.Extra), r.MaxExtraFields)}
+	}
+
+	if e.LatencyMs < 0 || (r.MaxLatencyMs > 0 && e.LatencyMs > r.MaxLatencyMs) {
+		return &ValidationError{"latency_ms", fmt.Sprintf("%d out of range [0, %d]", e.LatencyMs, r.MaxLatencyMs)}
+	}
+	if e.QueueLength < 0 || (r.MaxQueueLength > 0 && e.QueueLength > r.MaxQueueLength) {
+		return &ValidationError{"queue_length", fmt.Sprintf("%d out of range [0, %d]", e.QueueLength, r.MaxQueueLength)}
+	}
+
+	now := time.Now()
+	ts := time.UnixMilli(e.Timestamp)
+	if r.MaxAge > 0 && now.Sub(ts) > r.MaxAge {
+		return &ValidationError{"timestamp", fmt.Sprintf("older than %s", r.MaxAge)}
+	}
+	if r.MaxClockSkew > 0 && ts.Sub(now) > r.MaxClockSkew {
+		return &ValidationError{"timestamp", fmt.Sprintf("more than %s in the future", r.MaxClockSkew)}
+	}
+
+	return nil
+}
+
+// ParseAndValidate parses a JSON event and applies the rules to it
+func (r Rules) ParseAndValidate(data []byte) (*Event, error) {
+	return r.DecodeAndValidate(ContentTypeJSON, data)
+}
+
+// fieldValue returns a schema field rendered as a string, "" when unset
+func fieldValue(e *Event, field string) (string, bool) {
+	switch field {
+	case "event_id":
+		return e.EventID, true
+	case "trace_id":
+		return e.TraceID, true
+	case "service":
+		return e.Service, true
+	case "tenant_id":
+		return e.TenantID, true
+	case "status":
+		return e.Status, true
+	case "message":
+		return e.Message, true
+	case "timestamp":
+		return zeroAsEmpty(int(e.Timestamp)), true
+	case "latency_ms":
+		return zeroAsEmpty(e.LatencyMs), true
+	case "queue_length":
+		return zeroAsEmpty(e.QueueLength), true
+	}
+	return "", false
+}
+
+func zeroAsEmpty(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AttrFilter matches rows whose attributes hold every key/value pair.
+// Values match JSON strings, and also numbers or booleans when they parse
+// as one, so both ?attr.version=1.4.2 and ?attr.tier=2 work.
+type AttrFilter map[string]string
+
+// Where returns " AND ..." conditions on column, appending their
+// parameters to args. Each condition is a containment (@>) test, which
+// the GIN index on attributes serves; on SQLite it compares the key's
+// JSON value instead.
+func (f AttrFilter) Where(column string, args []interface{}, sqlite bool) (string, []interface{}) {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		v := f[k]
+		if sqlite {
+			path, _ := json.Marshal(k)
+			asString, _ := json.Marshal(v)
+			args = append(args, "$."+string(path), string(asString))
+			cond := fmt.Sprintf("%s -> $%d = $%d", column, len(args)-1, len(args))
+			if isJSONScalar(v) {
+				args = append(args, v)
+				cond = fmt.Sprintf("%s -> $%d IN ($%d, $%d)", column, len(args)-2, len(args)-1, len(args))
+			}
+			sb.WriteString(" AND ")
+			sb.WriteString(cond)
+			continue
+		}
+
+		asString, _ := json.Marshal(map[string]string{k: v})
+		args = append(args, string(asString))
+		cond := fmt.Sprintf("%s @> $%d", column, len(args))
+
+		if isJSONScalar(v) {
+			asScalar, _ := json.Marshal(map[string]json.RawMessage{k: json.RawMessage(v)})
+			args = append(args, string(asScalar))
+			cond = fmt.Sprintf("(%s OR %s @> $%d)", cond, column, len(args))
+		}
+		sb.WriteString(" AND ")
+		sb.WriteString(cond)
+	}
+	return sb.String(), args
+}
+
+// Matches reports whether attributes, a JSON object, holds every pair of
+// f, by the same rules as Where. It serves the in-memory stores.
+func (f AttrFilter) Matches(attributes []byte) bool {
+	if len(f) == 0 {
+		return true
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(attributes, &attrs); err != nil {
+		return false
+	}
+	for k, v := range f {
+		switch got := attrs[k].(type) {
+		case string:
+			if got != v {
+				return false
+			}
+		case float64, bool:
+			var want interface{}
+			if json.Unmarshal([]byte(v), &want) != nil || got != want {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isJSONScalar(v string) bool {
+	var x interface{}
+	if err := json.Unmarshal([]byte(v), &x); err != nil {
+		return false
+	}
+	switch x.(type) {
+	case float64, bool:
+		return true
+	}
+	return false
+}
+
+// AttributesOrEmpty is the value stored for a row without attributes
+func AttributesOrEmpty(attrs json.RawMessage) string {
+	if len(attrs) == 0 {
+		return "{}"
+	}
+	return string(attrs)
+}
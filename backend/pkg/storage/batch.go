@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Saver is a store that BatchWriter flushes into
+type Saver interface {
+	SaveContexts(events []SampledEvent) error
+}
+
+// BatchWriter accumulates context events and writes them to its store in
+// one go; a database's does it with a single COPY. It is not safe for
+// concurrent use; each consumer owns one.
+type BatchWriter struct {
+	store   Saver
+	metrics *BatchMetrics
+	maxSize int
+	maxWait time.Duration
+
+	pending []SampledEvent
+	rejects []func(error) error // one per pending event, may be nil
+	acks    []func()
+	first   time.Time // when the oldest pending entry was added
+}
+
+// BatchStats summarises batch flushes since startup
+type BatchStats struct {
+	Batches       int64   `json:"batches"`
+	Events        int64   `json:"events"`
+	Failures      int64   `json:"failures"`
+	LastBatchSize int     `json:"last_batch_size"`
+	LastFlushMs   float64 `json:"last_flush_ms"`
+	AvgFlushMs    float64 `json:"avg_flush_ms"`
+	MaxFlushMs    float64 `json:"max_flush_ms"`
+}
+
+// BatchMetrics collects the flushes of every BatchWriter of a store. The
+// zero value is ready to use.
+type BatchMetrics struct {
+	mu    sync.Mutex
+	stats BatchStats
+	total time.Duration
+}
+
+// NewBatchWriter returns a writer into store, recording its flushes in
+// metrics, that wants flushing once it holds maxSize events or its oldest
+// event has waited maxWait
+func NewBatchWriter(store Saver, metrics *BatchMetrics, maxSize int, maxWait time.Duration) *BatchWriter {
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	return &BatchWriter{store: store, metrics: metrics, maxSize: maxSize, maxWait: maxWait}
+}
+
+// Add queues an event kept by sampling with probability sampleRate (1
+// when unsampled). ack, if not nil, runs after the batch holding the
+// event is committed. reject, if not nil, takes the event over should
+// FlushEach find the store refusing it. It reports whether the batch is
+// full.
+func (w *BatchWriter) Add(event Event, sampleRate float64, ack func(), reject func(error) error) bool {
+	w.touch()
+	w.pending = append(w.pending, SampledEvent{Event: event, SampleRate: sampleRate})
+	w.rejects = append(w.rejects, reject)
+	if ack != nil {
+		w.acks = append(w.acks, ack)
+	}
+	return len(w.pending) >= w.maxSize
+}
+
+// Ack queues a callback with no event behind it, e.g. for a message that
+// was dead-lettered. It runs in order with the acks of pending events.
+func (w *BatchWriter) Ack(ack func()) {
+	if len(w.pending) == 0 && len(w.acks) == 0 {
+		ack()
+		return
+	}
+	w.touch()
+	w.acks = append(w.acks, ack)
+}
+
+// Due reports whether the oldest pending entry has waited maxWait
+func (w *BatchWriter) Due() bool {
+	return len(w.acks)+len(w.pending) > 0 && time.Since(w.first) >= w.maxWait
+}
+
+func (w *BatchWriter) Len() int {
+	return len(w.pending)
+}
+
+// Flush writes every pending event in one transaction and then runs the
+// queued acks. On failure nothing is acked and the batch is kept for the
+// next attempt.
+func (w *BatchWriter) Flush() error {
+	if len(w.pending) > 0 {
+		start := time.Now()
+		err := w.store.SaveContexts(w.pending)
+		w.metrics.record(len(w.pending), time.Since(start), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.done()
+	return nil
+}
+
+// FlushEach writes the pending events one at a time, for a batch whose
+// Flush failed on an event the store refuses (see Permanent). Each event
+// refused is handed to its reject callback and dropped from the batch.
+// It stops at the first other error, or a reject failing, keeping the
+// events not yet written for the next attempt; events already written
+// are skipped as duplicates then. Once all are through it runs the
+// queued acks.
+func (w *BatchWriter) FlushEach() error {
+	for len(w.pending) > 0 {
+		start := time.Now()
+		err := w.store.SaveContexts(w.pending[:1])
+		w.metrics.record(1, time.Since(start), err)
+		if err != nil {
+			if !Permanent(err) || w.rejects[0] == nil {
+				return err
+			}
+			if err := w.rejects[0](err); err != nil {
+				return err
+			}
+		}
+		w.pending = w.pending[1:]
+		w.rejects = w.rejects[1:]
+	}
+
+	w.done()
+	return nil
+}
+
+// done runs the queued acks and empties the batch
+func (w *BatchWriter) done() {
+	for _, ack := range w.acks {
+		ack()
+	}
+	w.pending = w.pending[:0]
+	w.rejects = w.rejects[:0]
+	w.acks = w.acks[:0]
+}
+
+func (w *BatchWriter) touch() {
+	if len(w.pending) == 0 && len(w.acks) == 0 {
+		w.first = time.Now()
+	}
+}
+
+// SaveContexts writes events into conn's contexts table inside a single
+// transaction, skipping those already stored (same ID and timestamp). On
+// Postgres rows are COPYed into a staging table first, since COPY itself
+// doesn't support ON CONFLICT.
+func SaveContexts(conn *sql.DB, sqlite bool, events []SampledEvent) error {
+	if sqlite {
+		return saveContextsSQLite(conn, events)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`CREATE TEMP TABLE contexts_staging (
+			event_id TEXT, trace_id TEXT, service TEXT, timestamp TIMESTAMP,
+			latency_ms INT, status TEXT, queue_length INT, message TEXT, attributes JSONB,
+			sample_rate DOUBLE PRECISION, tenant_id TEXT
+		 ) ON COMMIT DROP`,
+	); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("contexts_staging",
+		"event_id", "trace_id", "service", "timestamp", "latency_ms", "status", "queue_length", "message", "attributes",
+		"sample_rate", "tenant_id"))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if _, err := stmt.Exec(
+			e.EventID, e.TraceID, e.Service, time.UnixMilli(e.Timestamp).UTC(), e.LatencyMs, e.Status, e.QueueLength,
+			e.Message, e.Attributes(), e.SampleRate, TenantOf(e.TenantID),
+		); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO contexts(event_id, trace_id, service, timestamp, latency_ms, status, queue_length, message, attributes, sample_rate, tenant_id)
+		 SELECT event_id, trace_id, service, timestamp, latency_ms, status, queue_length, message, attributes, sample_rate, tenant_id
+		 FROM contexts_staging
+		 ON CONFLICT (event_id, timestamp) DO NOTHING`,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveContextsSQLite inserts events one by one inside a transaction;
+// SQLite has no COPY, and no need for it
+func saveContextsSQLite(conn *sql.DB, events []SampledEvent) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO contexts(event_id, trace_id, service, timestamp, latency_ms, status, queue_length, message, attributes, sample_rate, tenant_id)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 ON CONFLICT (event_id, timestamp) DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.Exec(
+			e.EventID, e.TraceID, e.Service, e.Timestamp, e.LatencyMs, e.Status, e.QueueLength,
+			e.Message, e.Attributes(), e.SampleRate, TenantOf(e.TenantID),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Snapshot returns the flush metrics so far
+func (m *BatchMetrics) Snapshot() BatchStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+func (m *BatchMetrics) record(size int, took time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms := float64(took.Microseconds()) / 1000
+	if err != nil {
+		m.stats.Failures++
+		return
+	}
+	m.stats.Batches++
+	m.stats.Events += int64(size)
+	m.stats.LastBatchSize = size
+	m.stats.LastFlushMs = ms
+	if ms > m.stats.MaxFlushMs {
+		m.stats.MaxFlushMs = ms
+	}
+	m.total += took
+	m.stats.AvgFlushMs = float64(m.total.Microseconds()) / 1000 / float64(m.stats.Batches)
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// refusingSaver stores events, refusing those with a bad ID as Postgres
+// would a constraint violation, and failing transiently while down
+type refusingSaver struct {
+	bad    string
+	down   bool
+	stored []string
+}
+
+func (s *refusingSaver) SaveContexts(events []SampledEvent) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	for _, e := range events {
+		if e.EventID == s.bad {
+			return &pq.Error{Code: "23514", Message: "check constraint violated"}
+		}
+	}
+	for _, e := range events {
+		s.stored = append(s.stored, e.EventID)
+	}
+	return nil
+}
+
+func TestBatchWriterFlushEachRejectsRefusedEvents(t *testing.T) {
+	saver := &refusingSaver{bad: "b"}
+	w := NewBatchWriter(saver, &BatchMetrics{}, 10, time.Minute)
+
+	var acked, rejected []string
+	for _, id := range []string{"a", "b", "c"} {
+		id := id
+		w.Add(Event{EventID: id}, 1, func() { acked = append(acked, id) }, func(err error) error {
+			if !Permanent(err) {
+				t.Errorf("reject(%v) for a non-permanent error", err)
+			}
+			rejected = append(rejected, id)
+			return nil
+		})
+	}
+
+	if err := w.Flush(); !Permanent(err) {
+		t.Fatalf("Flush() = %v, want a permanent error", err)
+	}
+	if len(acked) != 0 {
+		t.Fatalf("acked %v after a failed flush", acked)
+	}
+	if err := w.FlushEach(); err != nil {
+		t.Fatalf("FlushEach() = %v", err)
+	}
+
+	if got := saver.stored; len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Errorf("stored %v, want [a c]", got)
+	}
+	if len(rejected) != 1 || rejected[0] != "b" {
+		t.Errorf("rejected %v, want [b]", rejected)
+	}
+	if len(acked) != 3 {
+		t.Errorf("acked %v, want all three", acked)
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len() = %d after FlushEach, want 0", w.Len())
+	}
+}
+
+func TestBatchWriterFlushEachKeepsEventsOnTransientError(t *testing.T) {
+	saver := &refusingSaver{down: true}
+	w := NewBatchWriter(saver, &BatchMetrics{}, 10, time.Minute)
+	acks := 0
+	w.Add(Event{EventID: "a"}, 1, func() { acks++ }, func(error) error {
+		t.Error("reject called for a transient error")
+		return nil
+	})
+
+	if err := w.FlushEach(); err == nil || Permanent(err) {
+		t.Fatalf("FlushEach() = %v, want the transient error", err)
+	}
+	if w.Len() != 1 || acks != 0 {
+		t.Fatalf("Len() = %d, acks = %d; want the event kept unacked", w.Len(), acks)
+	}
+
+	saver.down = false
+	if err := w.FlushEach(); err != nil {
+		t.Fatalf("FlushEach() = %v", err)
+	}
+	if w.Len() != 0 || acks != 1 {
+		t.Errorf("Len() = %d, acks = %d; want the event written and acked", w.Len(), acks)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unique violation", &pq.Error{Code: "23505"}, true},
+		{"invalid text", &pq.Error{Code: "22P02"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, false},
+		{"connection", errors.New("dial tcp: connection refused"), false},
+		{"sqlite constraint", sqliteError(2067), true}, // SQLITE_CONSTRAINT_UNIQUE
+		{"sqlite busy", sqliteError(5), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Permanent(tt.err); got != tt.want {
+				t.Errorf("Permanent(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+type sqliteError int
+
+func (e sqliteError) Error() string { return "sqlite error" }
+func (e sqliteError) Code() int     { return int(e) }
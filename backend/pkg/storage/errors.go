@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// SQLite result codes of errors about the data itself
+const (
+	sqliteTooBig     = 18
+	sqliteConstraint = 19
+	sqliteMismatch   = 20
+)
+
+// Permanent reports whether err is the database refusing the data itself,
+// which retrying the same write can't fix: a Postgres data exception
+// (class 22) or integrity constraint violation (class 23), or SQLite's
+// equivalents. Connection errors, timeouts and the like are not.
+func Permanent(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", "23":
+			return true
+		}
+		return false
+	}
+
+	// modernc.org/sqlite errors, possibly with an extended code
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqliteTooBig, sqliteConstraint, sqliteMismatch:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/migrate"
+)
+
+// Migrator returns the schema migrator of a Postgres database. SQLite
+// files get their whole schema when opened and have none.
+func Migrator(conn *sql.DB, sqlite bool) (*migrate.Migrator, error) {
+	if sqlite {
+		return nil, errors.New("migrations apply to Postgres only; SQLite files get their schema when opened")
+	}
+	return migrate.New(conn)
+}
+
+// Migrate applies pending migrations to a Postgres database and returns
+// those it applied. It does nothing on SQLite.
+func Migrate(ctx context.Context, conn *sql.DB, sqlite bool) ([]migrate.Migration, error) {
+	if sqlite {
+		return nil, nil
+	}
+	m, err := Migrator(conn, sqlite)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up(ctx)
+}
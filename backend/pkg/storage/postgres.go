@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// OpenPostgres connects to the Postgres database at dsn, a URL or a
+// key=value connection string. Sessions are pinned to UTC: timestamp
+// columns hold UTC times without a zone, and the daily partitions of
+// migration 011 are UTC days, so both must not follow the server's
+// TimeZone setting.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	dsn, err := utcDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// utcDSN is dsn as a key=value connection string setting the session
+// TimeZone to UTC, overriding any timezone it set
+func utcDSN(dsn string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		opts, err := pq.ParseURL(dsn)
+		if err != nil {
+			return "", err
+		}
+		dsn = opts
+	}
+	if dsn == "" {
+		return "timezone=UTC", nil
+	}
+	return dsn + " timezone=UTC", nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import "testing"
+
+func TestUTCDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"", "timezone=UTC"},
+		{"host=db dbname=contextify sslmode=disable", "host=db dbname=contextify sslmode=disable timezone=UTC"},
+		{"host=db timezone=Asia/Kolkata", "host=db timezone=Asia/Kolkata timezone=UTC"},
+		{"postgres://postgres:postgres@db:5432/contextify?sslmode=disable",
+			"dbname='contextify' host='db' password='postgres' port='5432' sslmode='disable' user='postgres' timezone=UTC"},
+	}
+	for _, tt := range tests {
+		got, err := utcDSN(tt.dsn)
+		if err != nil || got != tt.want {
+			t.Errorf("utcDSN(%q) = %q, %v; want %q", tt.dsn, got, err, tt.want)
+		}
+	}
+	if _, err := utcDSN("postgres://db:port/contextify"); err == nil {
+		t.Error("utcDSN accepted a URL with a bad port")
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// SQLitePrefix marks a DSN naming a local SQLite file instead of a
+// Postgres server, e.g. "sqlite:/tmp/contextify.db". Contextify's dev mode
+// uses it so nothing has to run besides the process itself.
+const SQLitePrefix = "sqlite:"
+
+// SQLitePath returns the file named by a SQLite DSN
+func SQLitePath(dsn string) (string, bool) {
+	if !strings.HasPrefix(dsn, SQLitePrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(dsn, SQLitePrefix), true
+}
+
+// FromMillis is the SQL for storing the Unix-millisecond parameter n in a
+// timestamp column. SQLite stores the milliseconds as they are.
+func FromMillis(n int, sqlite bool) string {
+	if sqlite {
+		return "$" + strconv.Itoa(n)
+	}
+	return "to_timestamp($" + strconv.Itoa(n) + "/1000.0)"
+}
+
+// ToMillis is the SQL reading a timestamp column as Unix milliseconds
+func ToMillis(column string, sqlite bool) string {
+	if sqlite {
+		return column
+	}
+	return "extract(epoch from " + column + ")*1000"
+}
+
+// TimeRange bounds a query to rows timestamped in [From, To). A zero From
+// or To leaves that end open.
+type TimeRange struct {
+	From, To time.Time
+}
+
+// Contains reports whether the unix millis ts falls within r
+func (r TimeRange) Contains(ts int64) bool {
+	return (r.From.IsZero() || ts >= r.From.UnixMilli()) && (r.To.IsZero() || ts < r.To.UnixMilli())
+}
+
+// Where returns " AND ..." conditions keeping column within r, appending
+// their parameters to args
+func (r TimeRange) Where(column string, args []interface{}, sqlite bool) (string, []interface{}) {
+	var cond string
+	if !r.From.IsZero() {
+		args = append(args, r.From.UnixMilli())
+		cond += " AND " + column + " >= " + FromMillis(len(args), sqlite)
+	}
+	if !r.To.IsZero() {
+		args = append(args, r.To.UnixMilli())
+		cond += " AND " + column + " < " + FromMillis(len(args), sqlite)
+	}
+	return cond, args
+}
+
+// SetSampleRate reports a row's sample rate alongside its attributes, as
+// "sample_rate", so API clients can re-weight counts
+func SetSampleRate(e *Event, rate float64) {
+	if e.Extra == nil {
+		e.Extra = make(map[string]json.RawMessage)
+	}
+	e.Extra["sample_rate"], _ = json.Marshal(rate)
+}
+
+// QueryContexts fetches the tenant's newest contexts within span whose
+// column equals value, optionally only those matching attrs
+func QueryContexts(conn *sql.DB, sqlite bool, tenant, column, value string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error) {
+	within, args := span.Where("timestamp", []interface{}{tenant, value}, sqlite)
+	filter, args := attrs.Where("attributes", args, sqlite)
+	args = append(args, limit)
+	rows, err := conn.Query(
+		`SELECT event_id, trace_id, service, tenant_id, `+ToMillis("timestamp", sqlite)+` as ts, latency_ms, status, queue_length, message, attributes, sample_rate
+		 FROM contexts
+		 WHERE tenant_id = $1 AND `+column+` = $2`+within+filter+`
+		 ORDER BY timestamp DESC
+		 LIMIT $`+strconv.Itoa(len(args)), args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var ts float64
+		var attributes []byte
+		var sampleRate float64
+		if err := rows.Scan(&e.EventID, &e.TraceID, &e.Service, &e.TenantID, &ts, &e.LatencyMs, &e.Status, &e.QueueLength, &e.Message, &attributes, &sampleRate); err != nil {
+			return nil, err
+		}
+		if err := e.SetAttributes(attributes); err != nil {
+			return nil, err
+		}
+		SetSampleRate(&e, sampleRate)
+		e.SchemaVersion = schema.Version
+		e.Timestamp = int64(ts)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeRangeWhere(t *testing.T) {
+	from := time.UnixMilli(1000)
+	to := time.UnixMilli(2000)
+	tests := []struct {
+		name     string
+		span     TimeRange
+		sqlite   bool
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{"open", TimeRange{}, false, "", []interface{}{"acme"}},
+		{"postgres", TimeRange{From: from, To: to}, false,
+			" AND timestamp >= to_timestamp($2/1000.0) AND timestamp < to_timestamp($3/1000.0)", []interface{}{"acme", int64(1000), int64(2000)}},
+		{"sqlite from", TimeRange{From: from}, true, " AND timestamp >= $2", []interface{}{"acme", int64(1000)}},
+	}
+	for _, tt := range tests {
+		cond, args := tt.span.Where("timestamp", []interface{}{"acme"}, tt.sqlite)
+		if cond != tt.wantCond || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: Where = %q, %v; want %q, %v", tt.name, cond, args, tt.wantCond, tt.wantArgs)
+		}
+	}
+
+	span := TimeRange{From: from, To: to}
+	for ts, want := range map[int64]bool{999: false, 1000: true, 1999: true, 2000: false} {
+		if got := span.Contains(ts); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", ts, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Package storage holds the context storage shared by contextify and the
+// anomaly service: batched context writes, context queries, attribute and
+// time filters, the SQL differences between Postgres and SQLite, and
+// schema migration of their databases. Each service's db package builds
+// its Store on top of it.
+package storage
+
+import "github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+
+// Event is the canonical context event stored in contexts
+type Event = schema.Event
+
+// SampledEvent is a context event with the probability sampling gave it
+// of being stored. Each stored row stands for 1/SampleRate events.
+type SampledEvent struct {
+	Event
+	SampleRate float64
+}
+
+// TenantOf is the tenant a row is stored under
+func TenantOf(tenant string) string {
+	if tenant == "" {
+		return schema.DefaultTenant
+	}
+	return tenant
+}
@@ -0,0 +1,120 @@
+// Package tenant works out which tenant an API caller acts for, from the
+// API key it presents, and carries it through request contexts.
+//
+// With no keys configured a deployment is single-tenant: every caller
+// acts for schema.DefaultTenant and no credentials are asked for.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// Default is the tenant of single-tenant deployments and of events that
+// name none. It doubles as the operator's tenant: only its callers may use
+// the admin endpoints, which span tenants.
+const Default = schema.DefaultTenant
+
+// HeaderAPIKey is the header an API key may be sent in, besides
+// "Authorization: Bearer <key>". gRPC callers use the same names as
+// metadata keys.
+const HeaderAPIKey = "X-API-Key"
+
+// Keys maps API keys to the tenant each one acts for
+type Keys map[string]string
+
+// ParseKeys reads comma-separated "key=tenant" entries, e.g.
+//
+//	3f9c...=payments,a71e...=search
+func ParseKeys(s string) (Keys, error) {
+	keys := make(Keys)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, tenant, ok := strings.Cut(entry, "=")
+		key, tenant = strings.TrimSpace(key), strings.TrimSpace(tenant)
+		if !ok || key == "" || tenant == "" {
+			return nil, fmt.Errorf("API key entry %q: want key=tenant", entry)
+		}
+		keys[key] = tenant
+	}
+	return keys, nil
+}
+
+// Enabled reports whether callers must present a key
+func (k Keys) Enabled() bool {
+	return len(k) > 0
+}
+
+// Lookup returns the tenant a presented key acts for. Without keys
+// configured every caller acts for Default.
+func (k Keys) Lookup(key string) (string, bool) {
+	if !k.Enabled() {
+		return Default, true
+	}
+	tenant, ok := k[key]
+	return tenant, ok
+}
+
+// Middleware resolves the caller's tenant from its API key and stores it
+// in the request context, answering 401 to requests without a valid key.
+// Paths listed as public, e.g. health checks, need no key.
+func (k Keys) Middleware(public ...string) func(http.Handler) http.Handler {
+	open := make(map[string]bool, len(public))
+	for _, p := range public {
+		open[p] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := k.Lookup(RequestKey(r))
+			if !ok && !open[r.URL.Path] {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if !ok {
+				tenant = Default
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), tenant)))
+		})
+	}
+}
+
+// RequestKey returns the API key a request presents, "" when none
+func RequestKey(r *http.Request) string {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx acting for tenant
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant ctx acts for, Default when none was set
+func FromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(contextKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return Default
+}
+
+// IsOperator reports whether ctx acts for the operator's tenant
+func IsOperator(ctx context.Context) bool {
+	return FromContext(ctx) == Default
+}
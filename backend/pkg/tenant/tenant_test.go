@@ -0,0 +1,59 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	keys := Keys{"k-pay": "payments", "k-op": Default}
+	tests := []struct {
+		name       string
+		keys       Keys
+		path       string
+		header     string
+		value      string
+		wantStatus int
+		wantTenant string
+		wantOp     bool
+	}{
+		{"api key header", keys, "/api/events", HeaderAPIKey, "k-pay", http.StatusOK, "payments", false},
+		{"bearer token", keys, "/api/events", "Authorization", "Bearer k-pay", http.StatusOK, "payments", false},
+		{"operator key", keys, "/admin/dlq", HeaderAPIKey, "k-op", http.StatusOK, Default, true},
+		{"missing key", keys, "/api/events", "", "", http.StatusUnauthorized, "", false},
+		{"unknown key", keys, "/api/events", HeaderAPIKey, "nope", http.StatusUnauthorized, "", false},
+		{"public path without key", keys, "/health", "", "", http.StatusOK, Default, true},
+		{"public path with key", keys, "/health", HeaderAPIKey, "k-pay", http.StatusOK, "payments", false},
+		{"single-tenant", nil, "/api/events", "", "", http.StatusOK, Default, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTenant string
+			var gotOp bool
+			handler := tt.keys.Middleware("/health")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotTenant, gotOp = FromContext(r.Context()), IsOperator(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				if rec.Header().Get("WWW-Authenticate") != "Bearer" {
+					t.Error("401 without a WWW-Authenticate challenge")
+				}
+				return
+			}
+			if gotTenant != tt.wantTenant || gotOp != tt.wantOp {
+				t.Errorf("tenant = %q, operator %v; want %q, %v", gotTenant, gotOp, tt.wantTenant, tt.wantOp)
+			}
+		})
+	}
+}
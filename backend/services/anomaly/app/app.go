@@ -0,0 +1,261 @@
+// Package app wires the anomaly service together: the consumer feeding
+// the detector, and the HTTP API. cmd runs it against Kafka and Postgres;
+// contextify's dev mode embeds it on an in-memory bus and SQLite.
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/consumer"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/replay"
+)
+
+const anomaliesTopic = "anomalies"
+
+// Config is what the service needs from its host
+type Config struct {
+	DatabaseURL string  // Postgres DSN, or db.SQLitePrefix + path
+	Addr        string  // HTTP listen address
+	Bus         bus.Bus // events are consumed from and anomalies published to it
+	EventsTopic string
+	TenantKeys  tenant.Keys // API keys of the tenants; none serves one tenant openly
+}
+
+// Run starts the consumer and serves the HTTP API until ctx is cancelled
+func Run(ctx context.Context, cfg Config) error {
+	dbConn, err := db.NewDB(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+
+	// Bring the schema up to date; contextify may be doing the same, the
+	// migration lock makes one of them wait
+	applied, err := dbConn.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		logrus.Infof("⬆️ Applied migration %03d_%s", m.Version, m.Name)
+	}
+
+	// Initialize consumer with DB connection
+	consumer.Init(dbConn)
+	consumerCfg, err := consumer.ConfigFromEnv(cfg.EventsTopic)
+	if err != nil {
+		return err
+	}
+
+	// Start consumer in background; it stops with ctx
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := consumer.Start(ctx, consumerCfg, cfg.Bus); err != nil {
+			logrus.Fatalf("Consumer failed: %v", err)
+		}
+	}()
+
+	// Replays of the events topic through the detector read the bus
+	// directly, outside the consumer group
+	rules, err := schema.RulesFromEnv()
+	if err != nil {
+		return err
+	}
+	thresholds, err := detector.ThresholdsFromEnv()
+	if err != nil {
+		return err
+	}
+	replayer := replay.New(cfg.Bus, cfg.EventsTopic, dbConn, rules, thresholds)
+
+	server := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      NewRouter(dbConn, cfg.Bus, replayer, thresholds, int64(consumerCfg.LagThreshold), cfg.TenantKeys),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logrus.Infof("🌍 API listening on %s", cfg.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logrus.Info("🛑 Shutting down Anomaly service...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.Errorf("HTTP server forced to shutdown: %v", err)
+	}
+
+	// Let the consumer flush its last batches
+	<-consumerDone
+	return nil
+}
+
+// NewRouter builds the HTTP API. replayer may be nil, in which case the
+// replay endpoints answer 503. Partitions more than lagThreshold messages
+// behind are reported as lagging. Callers act for the tenant their API key
+// names; the replay endpoints and consumer progress span tenants and are
+// the operator's alone.
+func NewRouter(store db.Store, publisher bus.Publisher, replayer *replay.Replayer, thresholds detector.TenantThresholds, lagThreshold int64, keys tenant.Keys) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(loggingMiddleware)
+	router.Use(corsMiddleware)
+	router.Use(keys.Middleware("/health"))
+
+	// Health check
+	router.HandleFunc("/health", healthHandler).Methods("GET")
+
+	// Manual incident trigger
+	router.HandleFunc("/incident/trigger", func(w http.ResponseWriter, r *http.Request) {
+		triggerIncidentHandler(w, r, publisher)
+	}).Methods("POST")
+
+	// Metrics
+	router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler(w, r, store)
+	}).Methods("GET")
+
+	// Consumer lag and ingestion progress
+	router.HandleFunc("/ingest/status", func(w http.ResponseWriter, r *http.Request) {
+		ingestStatusHandler(w, r, lagThreshold)
+	}).Methods("GET")
+
+	// Anomaly detection status
+	router.HandleFunc("/anomalies/status", func(w http.ResponseWriter, r *http.Request) {
+		anomalyStatusHandler(w, r, thresholds)
+	}).Methods("GET")
+
+	// ✅ GET /anomalies?service=X&from=T&to=T&attr.K=V → anomalies + recent context
+	router.HandleFunc("/anomalies", func(w http.ResponseWriter, r *http.Request) {
+		service := r.URL.Query().Get("service")
+		if service == "" {
+			http.Error(w, "Missing 'service' query parameter", http.StatusBadRequest)
+			return
+		}
+
+		limit := 10
+		if lStr := r.URL.Query().Get("limit"); lStr != "" {
+			if l, err := strconv.Atoi(lStr); err == nil {
+				limit = l
+			}
+		}
+
+		var span db.TimeRange
+		var err error
+		if span.From, err = parseTime(r.URL.Query().Get("from")); err != nil {
+			http.Error(w, "Invalid 'from' query parameter", http.StatusBadRequest)
+			return
+		}
+		if span.To, err = parseTime(r.URL.Query().Get("to")); err != nil {
+			http.Error(w, "Invalid 'to' query parameter", http.StatusBadRequest)
+			return
+		}
+		attrs := parseAttrFilter(r.URL.Query())
+		caller := tenant.FromContext(r.Context())
+
+		anomalies, err := store.GetRecentAnomalies(caller, service, span, attrs, limit)
+		if err != nil {
+			http.Error(w, "Failed to fetch anomalies", http.StatusInternalServerError)
+			return
+		}
+
+		// Get recent context events for the service
+		ctxEvents, err := detector.AttachRecentContext(store, caller, service, span, attrs, limit)
+		if err != nil {
+			ctxEvents = []detector.Event{}
+		}
+
+		// Create response with anomalies and context
+		response := map[string]interface{}{
+			"anomalies":      anomalies,
+			"recent_context": ctxEvents,
+			"service":        service,
+			"timestamp":      time.Now().Format(time.RFC3339),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}).Methods("GET")
+
+	// POST /admin/replay {"from":"2024-01-01T00:00:00Z","target":"scratch"}
+	router.HandleFunc("/admin/replay", func(w http.ResponseWriter, r *http.Request) {
+		if !requireOperator(w, r) {
+			return
+		}
+		var req replay.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid replay request", http.StatusBadRequest)
+			return
+		}
+		if replayer == nil {
+			http.Error(w, "Replays are unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		progress, err := replayer.Start(req)
+		if errors.Is(err, replay.ErrBusy) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(progress)
+	}).Methods("POST")
+
+	// GET /admin/replay → progress and ETA of the current or last replay
+	router.HandleFunc("/admin/replay", func(w http.ResponseWriter, r *http.Request) {
+		if !requireOperator(w, r) {
+			return
+		}
+		if replayer == nil {
+			http.Error(w, "Replays are unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		progress, ok := replayer.Status()
+		if !ok {
+			http.Error(w, "No replay has run", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(progress)
+	}).Methods("GET")
+
+	// DELETE /admin/replay → cancel the running replay
+	router.HandleFunc("/admin/replay", func(w http.ResponseWriter, r *http.Request) {
+		if !requireOperator(w, r) {
+			return
+		}
+		if replayer == nil || !replayer.Cancel() {
+			http.Error(w, "No replay is running", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+
+	return router
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/consumer"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
+)
+
+// ----------------- Handlers -----------------
+
+// parseAttrFilter collects attr.K=V query parameters, first value wins
+func parseAttrFilter(q url.Values) db.AttrFilter {
+	attrs := db.AttrFilter{}
+	for key, values := range q {
+		name := strings.TrimPrefix(key, "attr.")
+		if name == key || name == "" || len(values) == 0 {
+			continue
+		}
+		attrs[name] = values[0]
+	}
+	return attrs
+}
+
+// parseTime reads RFC3339 or unix millis, the zero time when raw is empty
+func parseTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if millis, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "ok",
+		"service": "anomaly-detector",
+	})
+}
+
+// metricsHandler reports the service's health; the counters span tenants
+// and are left out for anyone but the operator
+func metricsHandler(w http.ResponseWriter, r *http.Request, store db.Store) {
+	metrics := map[string]interface{}{
+		"service":   "anomaly-detector",
+		"status":    "healthy",
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	if tenant.IsOperator(r.Context()) {
+		metrics["anomalies_detected"] = detector.AnomalyCount()
+		metrics["ingest"] = store.BatchStats()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+func ingestStatusHandler(w http.ResponseWriter, r *http.Request, threshold int64) {
+	if !requireOperator(w, r) {
+		return
+	}
+	snap := consumer.Status().Snapshot()
+
+	lagging := []int32{}
+	for _, p := range snap.Partitions {
+		if p.Lag > threshold {
+			lagging = append(lagging, p.Partition)
+		}
+	}
+
+	status := "ok"
+	if len(lagging) > 0 {
+		status = "lagging"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":             status,
+		"service":            "anomaly-detector",
+		"lag_threshold":      threshold,
+		"lagging_partitions": lagging,
+		"ingest":             snap,
+		"timestamp":          time.Now().Format(time.RFC3339),
+	})
+}
+
+// anomalyStatusHandler reports the thresholds the caller's events are
+// held to; the operator also sees every tenant's
+func anomalyStatusHandler(w http.ResponseWriter, r *http.Request, thresholds detector.TenantThresholds) {
+	caller := tenant.FromContext(r.Context())
+	status := map[string]interface{}{
+		"service":          "anomaly-detector",
+		"detection_active": true,
+		"tenant":           caller,
+		"thresholds":       thresholds.For(caller),
+		"timestamp":        time.Now().Format(time.RFC3339),
+	}
+	if tenant.IsOperator(r.Context()) {
+		status["tenant_thresholds"] = thresholds
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func triggerIncidentHandler(w http.ResponseWriter, r *http.Request, publisher bus.Publisher) {
+	anomaly := map[string]interface{}{
+		"type":        "manual_incident",
+		"service":     "contextify",
+		"tenant_id":   tenant.FromContext(r.Context()),
+		"status":      "anomaly_detected",
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"severity":    "high",
+		"description": "Manually triggered incident for testing",
+	}
+
+	payload, _ := json.Marshal(anomaly)
+	err := publisher.Publish(r.Context(), anomaliesTopic, bus.Message{
+		Key:     []byte("manual_incident"),
+		Value:   payload,
+		Headers: []bus.Header{{Key: schema.HeaderTenant, Value: []byte(tenant.FromContext(r.Context()))}},
+	})
+	if err != nil {
+		logrus.Errorf("Failed to publish manual incident: %v", err)
+		http.Error(w, "Failed to publish incident", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(payload)
+}
+
+// requireOperator answers 403 unless the caller acts for the operator's
+// tenant, for endpoints that span tenants
+func requireOperator(w http.ResponseWriter, r *http.Request) bool {
+	if !tenant.IsOperator(r.Context()) {
+		http.Error(w, "Admin endpoints are reserved to the operator", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// ----------------- Middleware -----------------
+
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		logrus.WithFields(logrus.Fields{
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"duration":   time.Since(start),
+			"user_agent": r.UserAgent(),
+		}).Info("HTTP request")
+	})
+}
+
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
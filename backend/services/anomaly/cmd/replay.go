@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/replay"
+)
+
+// runReplayCommand handles `anomaly replay [flags]`: replays events in the
+// foreground, logging progress, and returns the process exit code
+func runReplayCommand(cfg config, dbConn *db.DB, args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	from := fs.String("from", "", "replay from this time (RFC3339)")
+	since := fs.Duration("since", 0, "replay from this long ago, e.g. 24h")
+	startOffset := fs.Int64("start-offset", -1, "first offset to replay in each partition")
+	endOffset := fs.Int64("end-offset", -1, "stop before this offset in each partition")
+	partitions := fs.String("partitions", "", "comma-separated partitions (default all)")
+	target := fs.String("target", replay.TargetScratch, "where anomalies go: scratch or live")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	req := replay.Request{Target: *target}
+	switch {
+	case *from != "":
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			logrus.Errorf("Invalid -from: %v", err)
+			return 2
+		}
+		req.From = &t
+	case *since > 0:
+		t := time.Now().Add(-*since)
+		req.From = &t
+	}
+	if *startOffset >= 0 {
+		req.StartOffset = startOffset
+	}
+	if *endOffset >= 0 {
+		req.EndOffset = endOffset
+	}
+	for _, p := range strings.Split(*partitions, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			logrus.Errorf("Invalid -partitions: %v", err)
+			return 2
+		}
+		req.Partitions = append(req.Partitions, n)
+	}
+
+	rules, err := schema.RulesFromEnv()
+	if err != nil {
+		logrus.Errorf("Invalid validation rules: %v", err)
+		return 1
+	}
+	thresholds, err := detector.ThresholdsFromEnv()
+	if err != nil {
+		logrus.Errorf("Invalid thresholds: %v", err)
+		return 1
+	}
+
+	// Ctrl-C cancels the replay
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	eventBus := bus.NewKafka(cfg.kafkaBrokers)
+	defer eventBus.Close()
+
+	replayer := replay.New(eventBus, cfg.eventsTopic, dbConn, rules, thresholds)
+	p, err := replayer.Run(ctx, req, 5*time.Second, logReplayProgress)
+	logReplayProgress(p)
+	if err != nil {
+		logrus.Errorf("Replay %s: %v", p.State, err)
+		return 1
+	}
+	return 0
+}
+
+func logReplayProgress(p replay.Progress) {
+	logrus.WithFields(logrus.Fields{
+		"replay":    p.ID,
+		"state":     p.State,
+		"processed": p.Processed,
+		"total":     p.Total,
+		"anomalies": p.Anomalies,
+		"percent":   strconv.FormatFloat(p.Percent, 'f', 1, 64),
+		"eta":       (time.Duration(p.ETASeconds) * time.Second).String(),
+	}).Info("⏪ Replay progress")
+}
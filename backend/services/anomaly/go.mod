@@ -7,13 +7,50 @@ toolchain go1.24.6
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
-	github.com/segmentio/kafka-go v0.4.49
 	github.com/sirupsen/logrus v1.9.3
+	github.com/sujal-lgtm/Contextify/backend/pkg/bus v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/migrate v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/pipeline v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/producer v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/schema v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/tenant v0.0.0
+	modernc.org/sqlite v1.39.0
 )
 
+require github.com/segmentio/kafka-go v0.4.49 // indirect
+
 require (
-	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/hamba/avro/v2 v2.27.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.10 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/pierrec/lz4/v4 v4.1.15 // indirect
-	github.com/stretchr/testify v1.9.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	github.com/sujal-lgtm/Contextify/backend/pkg/storage v0.0.0
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/bus => ../../pkg/bus
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/pipeline => ../../pkg/pipeline
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/producer => ../../pkg/producer
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/schema => ../../pkg/schema
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/tenant => ../../pkg/tenant
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/migrate => ../../pkg/migrate
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/storage => ../../pkg/storage
@@ -0,0 +1,77 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+)
+
+// Config tunes the consumer
+type Config struct {
+	Topic            string
+	BatchSize        int           // flush after this many contexts...
+	FlushInterval    time.Duration // ...or once the oldest has waited this long
+	DedupWindow      time.Duration // how long event IDs are remembered
+	DedupMaxEntries  int
+	Workers          int // events are processed concurrently across services
+	WorkerQueueSize  int // events buffered per worker before reads block
+	LagThreshold     int // messages a partition may fall behind before consumer_lag is raised
+	LagCheckInterval time.Duration
+	MetricWindow     time.Duration // METRIC_THRESHOLDS are checked over this window...
+	MetricRefresh    time.Duration // ...refreshed this often
+	Sampling         pipeline.SamplingConfig
+}
+
+// ConfigFromEnv reads the consumer's settings for topic from the same
+// variables as contextify's consumer, failing on invalid values
+func ConfigFromEnv(topic string) (Config, error) {
+	c := Config{
+		Topic:            topic,
+		BatchSize:        500,
+		FlushInterval:    500 * time.Millisecond,
+		DedupWindow:      10 * time.Minute,
+		DedupMaxEntries:  100000,
+		Workers:          8,
+		WorkerQueueSize:  1000,
+		LagThreshold:     10000,
+		LagCheckInterval: 30 * time.Second,
+		MetricWindow:     time.Minute,
+		MetricRefresh:    15 * time.Second,
+	}
+
+	ints := map[string]*int{
+		"BATCH_SIZE":            &c.BatchSize,
+		"DEDUP_MAX_ENTRIES":     &c.DedupMaxEntries,
+		"WORKERS":               &c.Workers,
+		"WORKER_QUEUE_SIZE":     &c.WorkerQueueSize,
+		"LAG_WARNING_THRESHOLD": &c.LagThreshold,
+	}
+	for key, dst := range ints {
+		n, err := pipeline.EnvInt(key, *dst)
+		if err != nil {
+			return c, err
+		}
+		*dst = n
+	}
+
+	durations := map[string]*time.Duration{
+		"BATCH_FLUSH_INTERVAL": &c.FlushInterval,
+		"DEDUP_WINDOW":         &c.DedupWindow,
+		"LAG_CHECK_INTERVAL":   &c.LagCheckInterval,
+		"METRIC_WINDOW":        &c.MetricWindow,
+		"METRIC_REFRESH":       &c.MetricRefresh,
+	}
+	for key, dst := range durations {
+		d, err := pipeline.EnvDuration(key, *dst)
+		if err != nil {
+			return c, err
+		}
+		*dst = d
+	}
+
+	// Contexts are stored under the same sampling policies as
+	// contextify's, which being hash-based make the same decisions
+	var err error
+	c.Sampling, err = pipeline.SamplingFromEnv()
+	return c, err
+}
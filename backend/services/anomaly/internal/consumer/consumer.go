@@ -2,115 +2,522 @@ package consumer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
 
-	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
 
 	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
 	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
 )
 
-// Pass DB connection to consumer
-var dbConn *db.DB
+const (
+	groupID        = "anomaly-service"
+	anomaliesTopic = "anomalies"
+	dlqTopic       = "contextify-events-dlq"
+)
+
+// finalFlushTimeout bounds the flush of the last batches at shutdown,
+// which runs after the consumer's context is cancelled
+const finalFlushTimeout = 10 * time.Second
+
+// Store the consumer saves to, set by Init
+var dbConn db.Store
+
+// Consumer progress, reported on /ingest/status
+var status = pipeline.NewIngestStatus()
 
-func Init(db *db.DB) {
-	dbConn = db
+// Status returns the consumer's ingestion progress
+func Status() *pipeline.IngestStatus {
+	return status
 }
 
-func Start() error {
+func Init(store db.Store) {
+	dbConn = store
+}
+
+// Start consumes cfg.Topic from the bus with at-least-once semantics: a
+// message is acked only once its context row is durable and every anomaly
+// it raised has been stored and published, and the same holds for every
+// earlier message of its partition. Anomaly writes are keyed by event ID,
+// so a redelivered message never produces a second anomaly. A message
+// whose context or anomaly the database refuses outright is dead-lettered
+// instead. Start returns once ctx is cancelled and the pending batches
+// are flushed.
+func Start(ctx context.Context, cfg Config, b bus.Bus) error {
 	if dbConn == nil {
-		logrus.Fatal("DB connection not initialized. Call Init(db) first.")
+		logrus.Fatal("Store not initialized. Call Init(store) first.")
+	}
+
+	// VALIDATION_* variables override the default schema rules
+	rules, err := schema.RulesFromEnv()
+	if err != nil {
+		return err
 	}
 
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{"kafka:9092"},
-		Topic:   "contextify-events",
-		GroupID: "anomaly-service",
+	// A group with nothing acked yet reads the topic from the start
+	sub, err := b.Subscribe(cfg.Topic, groupID, bus.StartAt(bus.StartOldest))
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	dlq := producer.New(b, dlqTopic)
+
+	logrus.Infof("📡 Listening for events on %s...", cfg.Topic)
+
+	// Warn about the pipeline itself when we fall behind
+	go status.WatchLag(ctx, int64(cfg.LagThreshold), cfg.LagCheckInterval, func(p pipeline.PartitionStatus) {
+		reportLag(ctx, b, p)
 	})
-	defer r.Close()
 
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{"kafka:9092"},
-		Topic:   "anomalies",
+	// Recently seen event IDs
+	dedup := pipeline.NewDedup(cfg.DedupWindow, cfg.DedupMaxEntries)
+
+	// The detector sees every event, but only sampled contexts are stored
+	sampler := cfg.Sampling.NewSampler(cfg.DedupMaxEntries)
+
+	// THRESHOLD_* and TENANT_THRESHOLDS override the built-in rules'
+	// thresholds
+	limits, err := detector.ThresholdsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	// METRIC_THRESHOLDS ("name>max,...") turns Prometheus metrics stored
+	// by contextify into additional signals
+	thresholds, err := detector.ParseMetricThresholds(os.Getenv("METRIC_THRESHOLDS"))
+	if err != nil {
+		return err
+	}
+	var metrics *detector.MetricSignals
+	if len(thresholds) > 0 {
+		metrics = detector.NewMetricSignals(dbConn, thresholds,
+			cfg.MetricWindow, cfg.MetricRefresh)
+	}
+
+	// Events are processed on a pool of workers keyed by service, so each
+	// service's events run in order on one worker while different services
+	// proceed in parallel. Detector state and context batches live on the
+	// worker that owns them.
+	workers := make([]*worker, cfg.Workers)
+	for i := range workers {
+		workers[i] = &worker{
+			ctx:      ctx,
+			bus:      b,
+			batch:    dbConn.NewBatchWriter(cfg.BatchSize, cfg.FlushInterval),
+			dedup:    dedup,
+			inflight: make(map[string]bool),
+			trackers: make(map[string]*detector.ErrorRateTracker),
+			limits:   limits,
+			metrics:  metrics,
+		}
+	}
+	pool := pipeline.NewPool(pipeline.PoolConfig{
+		Workers:   len(workers),
+		QueueSize: cfg.WorkerQueueSize,
+		OnTick:    func(i int) { workers[i].flushDue() },
+		OnClose:   func(i int) { workers[i].flushFinal() },
+		TickEvery: cfg.FlushInterval / 2,
 	})
-	defer w.Close()
 
-	logrus.Info("📡 Listening for events on contextify-events...")
+	// Offsets complete out of order across workers; ack each partition
+	// only up to its first unfinished message
+	offsets := make(map[int]*pipeline.OffsetTracker)
+	committed := make(map[int]int64)
+	commit := func(ctx context.Context) {
+		var msgs []bus.Message
+		for partition, t := range offsets {
+			if offset, ok := t.Committable(); ok && offset > committed[partition] {
+				msgs = append(msgs, bus.Message{Topic: cfg.Topic, Partition: partition, Offset: offset})
+			}
+		}
+		if len(msgs) == 0 {
+			return
+		}
+		if err := withRetry(ctx, "offset commit", func() error {
+			return sub.Ack(ctx, msgs...)
+		}); err != nil {
+			return
+		}
+		for _, m := range msgs {
+			committed[m.Partition] = m.Offset
+		}
+	}
 
-	// Error rate tracker (window: 10 seconds)
-	tracker := detector.NewErrorRateTracker(10)
+	// On the way out, flush every worker's last batch and commit what
+	// they acked
+	defer func() {
+		pool.Close()
+		finalCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+		defer cancel()
+		commit(finalCtx)
+		logrus.Info("🛑 Consumer shutting down...")
+	}()
 
-	for {
-		m, err := r.ReadMessage(context.Background())
+	readBackoff := newBackoff()
+	lastCommit := time.Now()
+	for ctx.Err() == nil {
+		if time.Since(lastCommit) >= cfg.FlushInterval {
+			commit(ctx)
+			lastCommit = time.Now()
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, cfg.FlushInterval)
+		m, err := sub.Fetch(fetchCtx)
+		cancel()
 		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				continue
+			}
+			if errors.Is(err, bus.ErrClosed) {
+				return nil
+			}
 			logrus.Errorf("❌ failed to read message: %v", err)
+			readBackoff.wait(ctx)
 			continue
 		}
+		readBackoff.reset()
+
+		t, ok := offsets[m.Partition]
+		if !ok {
+			t = pipeline.NewOffsetTracker()
+			offsets[m.Partition] = t
+			committed[m.Partition] = -1
+		}
+		if !t.Track(m.Offset) {
+			// The partition was rewound (rebalance); start tracking afresh
+			t = pipeline.NewOffsetTracker()
+			t.Track(m.Offset)
+			offsets[m.Partition] = t
+		}
+		status.Observe(m.Topic, int32(m.Partition), m.Offset, m.HighWaterMark)
+
+		offset := m.Offset
+		var eventTime int64
+		done := func() {
+			t.Done(offset)
+			status.Processed(m.Topic, int32(m.Partition), offset, eventTime)
+		}
+
+		// Hands the message to the DLQ, for the workers when the database
+		// refuses what it carries
+		reject := func(reason error) error {
+			return withRetry(ctx, "dead-letter publish", func() error { return deadLetter(dlq, m, reason) })
+		}
 
-		event, err := detector.ParseEvent(m.Value)
+		event, err := rules.DecodeAndValidateAt(m.Header(schema.HeaderContentType), m.Value, m.Time)
 		if err != nil {
-			logrus.Errorf("❌ failed to parse event: %v", err)
+			if reject(err) == nil {
+				done()
+			}
 			continue
 		}
 
-		logrus.Infof(" Received event: %+v", event)
+		eventTime = event.Timestamp
+		event.SetTenant(m.Header(schema.HeaderTenant))
+
+		// Producer retries and redeliveries carry the same ID; don't let
+		// them feed the detector twice. An ID is recorded by the worker
+		// once its event is handled, so the redelivery of one whose batch
+		// failed is processed after all.
+		event.EnsureEventID(m.Header(schema.HeaderEventID), m.Topic, m.Partition, m.Offset)
+		if dedup.Contains(event.EventID) {
+			logrus.Debugf("Skipping duplicate event %s", event.EventID)
+			done()
+			continue
+		}
 
-		// 1️⃣ Persist context to DB
-		if err := dbConn.SaveContext(db.Event{
-			TraceID:     event.TraceID,
-			Service:     event.Service,
-			Timestamp:   event.Timestamp,
-			LatencyMs:   event.LatencyMs,
-			Status:      event.Status,
-			QueueLength: event.QueueLength,
+		store, sampleRate := sampler.Sample(event.Service, event.TraceID, event.EventID, event.Status != "success", event.LatencyMs)
+
+		// Blocks while the service's worker is backed up, which in turn
+		// stops us fetching. It fails only once ctx is done; the message is
+		// left unfinished then, holding back its partition's commit, and
+		// is redelivered.
+		e := *event
+		if err := pool.Submit(ctx, e.TenantID+"/"+e.Service, func(i int) {
+			workers[i].process(e, store, sampleRate, done, reject)
 		}); err != nil {
-			logrus.Errorf("Failed to save context: %v", err)
+			return nil
 		}
+	}
+	return nil
+}
 
-		// 2️⃣ Add event to error rate tracker
-		tracker.AddEvent(*event)
+// worker holds the state owned by one pool worker
+type worker struct {
+	ctx      context.Context // cancelled at shutdown
+	bus      bus.Publisher
+	batch    *db.BatchWriter
+	dedup    *pipeline.Dedup
+	inflight map[string]bool                       // IDs processed but not yet done
+	trackers map[string]*detector.ErrorRateTracker // per tenant and service
+	limits   detector.TenantThresholds
+	metrics  *detector.MetricSignals // shared; nil without METRIC_THRESHOLDS
+}
+
+// process runs the detector for one event and queues its context row,
+// unless sampling left it out (store false). done runs once the row is
+// durable, after every anomaly it raised has been stored and published,
+// or once reject has dead-lettered an event the database refuses; only
+// then is the event's ID recorded as seen. An event cut short by shutdown
+// is left undone, to be redelivered.
+func (wk *worker) process(event detector.Event, store bool, sampleRate float64, done func(), reject func(error) error) {
+	logrus.Infof(" Received event: %+v", event)
+
+	// A redelivery of an event already handled, or still waiting on its
+	// batch, is done along with the original
+	id := event.EventID
+	if wk.inflight[id] || wk.dedup.Contains(id) {
+		wk.batch.Ack(done)
+		return
+	}
+	wk.inflight[id] = true
+	handled := func() {
+		delete(wk.inflight, id)
+		wk.dedup.Seen(id)
+		done()
+	}
 
-		anomaliesDetected := false
+	// 1️⃣ Add event to the service's error rate tracker (window: 10 seconds)
+	key := event.TenantID + "/" + event.Service
+	tracker, ok := wk.trackers[key]
+	if !ok {
+		tracker = detector.NewErrorRateTracker(10)
+		wk.trackers[key] = tracker
+	}
+	tracker.AddEvent(event)
 
-		// 3️⃣ Check latency spike
-		if detector.CheckLatency(*event, 300) {
-			detector.PersistAnomaly(dbConn, *event, "latency_spike", 0)
-			msg := detector.CreateAnomalyMessage(*event, "latency_spike")
-			writeKafkaMessage(w, msg)
-			anomaliesDetected = true
+	// 2️⃣ Run the checks and store/publish whatever fired
+	if err := detectAnomalies(wk.ctx, wk.bus, tracker, wk.limits.For(event.TenantID), wk.metrics, event); err != nil {
+		if !db.Permanent(err) || reject(err) != nil {
+			delete(wk.inflight, id)
+			return
 		}
+		wk.batch.Ack(handled)
+		return
+	}
+
+	// 3️⃣ Queue context for the next batch write
+	if !store {
+		wk.batch.Ack(handled)
+		return
+	}
+	if wk.batch.Add(event, sampleRate, handled, reject) {
+		wk.flush(wk.ctx)
+	}
+}
+
+func (wk *worker) flushDue() {
+	if wk.batch.Due() {
+		wk.flush(wk.ctx)
+	}
+}
+
+// flushFinal flushes what is left once the pool closes. The consumer's
+// context is cancelled by then, so it gets one of its own.
+func (wk *worker) flushFinal() {
+	ctx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+	defer cancel()
+	wk.flush(ctx)
+}
 
-		// 4️⃣ Check error rate spike
-		if tracker.CheckErrorRate(0.5) {
-			detector.PersistAnomaly(dbConn, *event, "error_rate_spike", 0.5)
-			msg := detector.CreateAnomalyMessage(*event, "error_rate_spike")
-			writeKafkaMessage(w, msg)
-			anomaliesDetected = true
+// flush writes the batch, retrying until ctx is done. A batch the
+// database refuses is written event by event instead, so the events it
+// refuses can be dead-lettered and the rest stored.
+func (wk *worker) flush(ctx context.Context) {
+	err := withRetry(ctx, "context batch flush", wk.batch.Flush)
+	if db.Permanent(err) {
+		logrus.Warnf("Context batch refused, writing its events one by one: %v", err)
+		err = withRetry(ctx, "context write", wk.batch.FlushEach)
+	}
+	if err != nil {
+		logrus.Errorf("❌ Context batch not flushed, its events will be redelivered: %v", err)
+	}
+}
+
+// detectAnomalies evaluates the thresholds for one event. It returns once
+// every anomaly raised is both persisted and published, or with the error
+// that stopped one.
+func detectAnomalies(ctx context.Context, p bus.Publisher, tracker *detector.ErrorRateTracker, th detector.Thresholds, metrics *detector.MetricSignals, event detector.Event) error {
+	raised, errorRate := detector.Evaluate(tracker, event, th)
+
+	for _, anomalyType := range raised {
+		// Same event, same anomaly, same key: replays hit the unique index
+		key := event.EventID + "/" + anomalyType
+		rate := 0.0
+		if anomalyType == "error_rate_spike" {
+			rate = errorRate
 		}
+		if err := raise(ctx, p, event, anomalyType, rate, key); err != nil {
+			return err
+		}
+	}
 
-		// 5️⃣ Check queue length threshold
-		if detector.CheckQueueLength(*event, 100) {
-			detector.PersistAnomaly(dbConn, *event, "queue_length_spike", 0)
-			msg := detector.CreateAnomalyMessage(*event, "queue_length_spike")
-			writeKafkaMessage(w, msg)
-			anomaliesDetected = true
+	// Prometheus metrics for the service, when thresholds are configured.
+	// Keyed per minute rather than per event: one anomaly a minute while
+	// the metric stays high.
+	if metrics != nil {
+		for _, b := range metrics.Breaches(event.TenantID, event.Service) {
+			key := fmt.Sprintf("metric/%s/%s/%s/%d", event.TenantID, event.Service, b.Name, time.Now().Unix()/60)
+			signal := event
+			signal.Extra = map[string]json.RawMessage{}
+			for k, v := range event.Extra {
+				signal.Extra[k] = v
+			}
+			signal.Extra["metric"], _ = json.Marshal(b.Name)
+			signal.Extra["metric_value"], _ = json.Marshal(b.Value)
+			signal.Extra["metric_max"], _ = json.Marshal(b.Max)
+			if err := raise(ctx, p, signal, "metric_threshold", 0, key); err != nil {
+				return err
+			}
+			raised = append(raised, "metric_threshold")
 		}
+	}
 
-		if anomaliesDetected {
-			logrus.Warnf("🚨 Anomaly detected for service: %s", event.Service)
-			detector.IncrementAnomalyCount()
+	if len(raised) > 0 {
+		logrus.Warnf("🚨 Anomaly detected for service: %s", event.Service)
+		detector.IncrementAnomalyCount()
+	}
+	return nil
+}
+
+// reportLag stores and publishes a consumer_lag anomaly for a partition
+// that fell behind. The key buckets by time, so a partition that stays
+// behind raises one anomaly every lagCooldown rather than one per check.
+func reportLag(ctx context.Context, pub bus.Publisher, p pipeline.PartitionStatus) {
+	const lagCooldown = 5 * time.Minute
+
+	now := time.Now()
+	key := "pipeline/consumer_lag/" + strconv.Itoa(int(p.Partition)) + "/" + strconv.FormatInt(now.Unix()/int64(lagCooldown.Seconds()), 10)
+	event := detector.Event{
+		Service:     groupID,
+		Timestamp:   now.UnixMilli(),
+		QueueLength: int(p.Lag),
+		Status:      "warning",
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"topic":     p.Topic,
+		"partition": p.Partition,
+		"lag":       p.Lag,
+	}).Warn("🐢 Consumer lag above threshold")
+
+	if err := raise(ctx, pub, event, "consumer_lag", 0, key); err != nil {
+		logrus.Errorf("❌ Failed to report consumer lag: %v", err)
+		return
+	}
+	detector.IncrementAnomalyCount()
+}
+
+// raise persists an anomaly under key, then publishes it unless a
+// previous attempt already did
+func raise(ctx context.Context, p bus.Publisher, event detector.Event, anomalyType string, errorRate float64, key string) error {
+	if err := withRetry(ctx, "anomaly persist", func() error {
+		return detector.PersistAnomaly(dbConn, event, anomalyType, errorRate, key)
+	}); err != nil {
+		return err
+	}
+
+	var published bool
+	if err := withRetry(ctx, "anomaly lookup", func() (err error) {
+		published, err = dbConn.AnomalyPublished(key)
+		return err
+	}); err != nil || published {
+		return err
+	}
+
+	msg := detector.CreateAnomalyMessage(event, anomalyType, key)
+	if err := withRetry(ctx, "anomaly publish", func() error { return publishAnomaly(ctx, p, event.TenantID, key, msg) }); err != nil {
+		return err
+	}
+	return withRetry(ctx, "anomaly publish mark", func() error { return dbConn.MarkAnomalyPublished(key) })
+}
+
+// withRetry runs fn until it succeeds, backing off between attempts. An
+// error the database refuses the data with (see db.Permanent) is returned
+// at once, as is ctx's error once ctx is done. Anything else is treated
+// as transient: giving up would mean committing past a message whose side
+// effects never happened.
+func withRetry(ctx context.Context, what string, fn func() error) error {
+	b := newBackoff()
+	for {
+		err := fn()
+		if err == nil || db.Permanent(err) {
+			return err
 		}
+		logrus.Warnf("Retrying %s in %s: %v", what, b.next, err)
+		if !b.wait(ctx) {
+			return fmt.Errorf("%s: %w", what, ctx.Err())
+		}
+	}
+}
+
+type backoff struct {
+	next time.Duration
+}
+
+func newBackoff() *backoff {
+	return &backoff{next: 200 * time.Millisecond}
+}
+
+// wait sleeps for the current delay and doubles it. It reports false,
+// early, when ctx is done.
+func (b *backoff) wait(ctx context.Context) bool {
+	t := time.NewTimer(b.next)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+	}
+	if b.next < 30*time.Second {
+		b.next *= 2
+	}
+	return true
+}
+
+func (b *backoff) reset() {
+	b.next = 200 * time.Millisecond
+}
+
+// Helper to forward a rejected message to the DLQ
+func deadLetter(dlq *producer.Publisher, m bus.Message, reason error) error {
+	err := dlq.PublishDeadLetter(context.Background(), producer.DeadLetter{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Headers:   m.Headers,
+		Consumer:  groupID,
+		Reason:    reason.Error(),
+	})
+	if err != nil {
+		return err
 	}
+	logrus.Warnf("☠️ Event at %d/%d rejected and sent to DLQ: %v", m.Partition, m.Offset, reason)
+	return nil
 }
 
-// Helper to write anomaly to Kafka
-func writeKafkaMessage(w *kafka.Writer, msg string) {
-	err := w.WriteMessages(context.Background(), kafka.Message{
-		Value: []byte(msg),
+// Helper to publish anomaly to the anomalies topic
+func publishAnomaly(ctx context.Context, p bus.Publisher, tenantID, key, msg string) error {
+	err := p.Publish(ctx, anomaliesTopic, bus.Message{
+		Key:     []byte(key),
+		Value:   []byte(msg),
+		Headers: []bus.Header{{Key: schema.HeaderTenant, Value: []byte(tenantID)}},
 	})
 	if err != nil {
-		logrus.Errorf("Failed to write anomaly to Kafka: %v", err)
-	} else {
-		logrus.Infof("⚠️ Anomaly published to Kafka: %s", msg)
+		return err
 	}
+	logrus.Infof("⚠️ Anomaly published: %s", msg)
+	return nil
 }
@@ -0,0 +1,103 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
+)
+
+// flakySaver fails every write while down
+type flakySaver struct {
+	down   bool
+	stored []string
+}
+
+func (s *flakySaver) SaveContexts(events []db.SampledEvent) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	for _, e := range events {
+		s.stored = append(s.stored, e.EventID)
+	}
+	return nil
+}
+
+func newTestWorker(ctx context.Context, saver storage.Saver) *worker {
+	return &worker{
+		ctx:      ctx,
+		batch:    storage.NewBatchWriter(saver, &storage.BatchMetrics{}, 10, time.Minute),
+		dedup:    pipeline.NewDedup(time.Minute, 100),
+		inflight: make(map[string]bool),
+		trackers: make(map[string]*detector.ErrorRateTracker),
+		limits:   detector.TenantThresholds{Default: detector.DefaultThresholds()},
+	}
+}
+
+func TestWorkerRecordsEventSeenOnlyOnceStored(t *testing.T) {
+	saver := &flakySaver{down: true}
+	wk := newTestWorker(context.Background(), saver)
+	event := detector.Event{EventID: "e1", Service: "checkout", TenantID: "acme", Status: "success", Timestamp: 1}
+
+	dones := 0
+	done := func() { dones++ }
+	wk.process(event, true, 1, done, nil)
+
+	// The database is down through shutdown: nothing is done or recorded
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	wk.flush(stopped)
+	if dones != 0 {
+		t.Fatalf("done ran %d times for an unstored event", dones)
+	}
+	if wk.dedup.Contains("e1") {
+		t.Fatal("event recorded as seen before it was stored")
+	}
+
+	// The redelivery waits on the original instead of being dropped
+	wk.process(event, true, 1, done, nil)
+	saver.down = false
+	wk.flush(context.Background())
+	if len(saver.stored) != 1 || dones != 2 {
+		t.Fatalf("stored %v with %d dones, want the event once and both deliveries done", saver.stored, dones)
+	}
+	if !wk.dedup.Contains("e1") {
+		t.Error("stored event not recorded as seen")
+	}
+
+	// Later redeliveries are done without another write
+	wk.process(event, true, 1, done, nil)
+	wk.flush(context.Background())
+	if len(saver.stored) != 1 || dones != 3 {
+		t.Errorf("stored %v with %d dones after a late redelivery", saver.stored, dones)
+	}
+}
+
+func TestWorkerRedeliveryAfterRestartIsStored(t *testing.T) {
+	event := detector.Event{EventID: "e1", Service: "checkout", TenantID: "acme", Status: "success", Timestamp: 1}
+
+	// First run: shut down before the batch could be written
+	down := &flakySaver{down: true}
+	wk := newTestWorker(context.Background(), down)
+	wk.process(event, true, 1, func() { t.Error("done ran for an unstored event") }, nil)
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	wk.flush(stopped)
+
+	// Second run, sharing the dedup as a rebalance within one process would
+	saver := &flakySaver{}
+	next := newTestWorker(context.Background(), saver)
+	next.dedup = wk.dedup
+	dones := 0
+	next.process(event, true, 1, func() { dones++ }, nil)
+	next.flush(context.Background())
+	if len(saver.stored) != 1 || dones != 1 {
+		t.Errorf("stored %v with %d dones, want the redelivered event stored", saver.stored, dones)
+	}
+}
@@ -2,54 +2,128 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"strconv"
 
 	_ "github.com/lib/pq"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
 )
 
 type DB struct {
-	Conn *sql.DB
+	Conn    *sql.DB
+	batches *storage.BatchMetrics
+	sqlite  bool // Conn is a SQLite file rather than Postgres
 }
 
-// Initialize DB connection
+// Initialize DB connection. A DSN starting with SQLitePrefix opens a local
+// SQLite file instead of Postgres.
 func NewDB(dsn string) (*DB, error) {
-	conn, err := sql.Open("postgres", dsn)
-	if err != nil {
-		return nil, err
+	if path, ok := storage.SQLitePath(dsn); ok {
+		conn, err := openSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{Conn: conn, batches: &storage.BatchMetrics{}, sqlite: true}, nil
 	}
-	if err := conn.Ping(); err != nil {
+
+	conn, err := storage.OpenPostgres(dsn)
+	if err != nil {
 		return nil, err
 	}
-	return &DB{Conn: conn}, nil
+	return &DB{Conn: conn, batches: &storage.BatchMetrics{}}, nil
 }
 
-// Save a context event
+// Save a context event. Events already stored under the same ID are skipped.
 func (db *DB) SaveContext(event Event) error {
 	_, err := db.Conn.Exec(
-		`INSERT INTO contexts(trace_id, service, timestamp, latency_ms, status, queue_length)
-         VALUES($1, $2, to_timestamp($3/1000.0), $4, $5, $6)`,
-		event.TraceID, event.Service, event.Timestamp, event.LatencyMs, event.Status, event.QueueLength,
+		`INSERT INTO contexts(event_id, trace_id, service, timestamp, latency_ms, status, queue_length, message, attributes, tenant_id)
+		 VALUES($1, $2, $3, `+db.fromMillis(4)+`, $5, $6, $7, $8, $9, $10)
+		 ON CONFLICT (event_id, timestamp) DO NOTHING`,
+		event.EventID, event.TraceID, event.Service, event.Timestamp, event.LatencyMs, event.Status, event.QueueLength,
+		event.Message, event.Attributes(), storage.TenantOf(event.TenantID),
 	)
 	return err
 }
 
-// Save an anomaly
+// Save an anomaly. Anomalies carrying a DedupKey are written at most once:
+// the key is claimed in anomaly_keys in the same transaction, since the
+// partitioned anomalies table can't hold a unique index on it alone.
 func (db *DB) SaveAnomaly(a Anomaly) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if a.DedupKey != "" {
+		res, err := tx.Exec(
+			`INSERT INTO anomaly_keys(dedup_key) VALUES($1) ON CONFLICT (dedup_key) DO NOTHING`, a.DedupKey,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO anomalies(type, service, trace_id, latency_ms, error_rate, queue_length, timestamp, dedup_key, attributes, tenant_id)
+		 VALUES($1, $2, $3, $4, $5, $6, `+db.fromMillis(7)+`, NULLIF($8, ''), $9, $10)`,
+		a.Type, a.Service, a.TraceID, a.LatencyMs, a.ErrorRate, a.QueueLength, a.Timestamp, a.DedupKey,
+		storage.AttributesOrEmpty(a.Attributes), storage.TenantOf(a.TenantID),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveReplayAnomaly writes an anomaly raised by a replay into the scratch
+// table. Each replay writes a given DedupKey once.
+func (db *DB) SaveReplayAnomaly(replayID string, a Anomaly) error {
+	_, err := db.Conn.Exec(
+		`INSERT INTO anomalies_replay(replay_id, type, service, trace_id, latency_ms, error_rate, queue_length, timestamp, dedup_key, attributes, tenant_id)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, `+db.fromMillis(8)+`, $9, $10, $11)
+		 ON CONFLICT (replay_id, dedup_key) DO NOTHING`,
+		replayID, a.Type, a.Service, a.TraceID, a.LatencyMs, a.ErrorRate, a.QueueLength, a.Timestamp, a.DedupKey,
+		storage.AttributesOrEmpty(a.Attributes), storage.TenantOf(a.TenantID),
+	)
+	return err
+}
+
+// AnomalyPublished reports whether the anomaly with this key reached Kafka
+func (db *DB) AnomalyPublished(dedupKey string) (bool, error) {
+	var published bool
+	err := db.Conn.QueryRow(
+		`SELECT published FROM anomaly_keys WHERE dedup_key = $1`, dedupKey,
+	).Scan(&published)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return published, err
+}
+
+// MarkAnomalyPublished records that the anomaly with this key reached Kafka
+func (db *DB) MarkAnomalyPublished(dedupKey string) error {
 	_, err := db.Conn.Exec(
-		`INSERT INTO anomalies(type, service, latency_ms, error_rate, queue_length, timestamp)
-		 VALUES($1, $2, $3, $4, $5, to_timestamp($6/1000.0))`,
-		a.Type, a.Service, a.LatencyMs, a.ErrorRate, a.QueueLength, a.Timestamp,
+		`UPDATE anomaly_keys SET published = TRUE WHERE dedup_key = $1`, dedupKey,
 	)
 	return err
 }
 
-// Fetch recent anomalies
-func (db *DB) GetRecentAnomalies(service string, limit int) ([]Anomaly, error) {
+// Fetch a tenant's recent anomalies within span, optionally only those
+// matching attrs
+func (db *DB) GetRecentAnomalies(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Anomaly, error) {
+	within, args := span.Where("timestamp", []interface{}{tenant, service}, db.sqlite)
+	filter, args := attrs.Where("attributes", args, db.sqlite)
+	args = append(args, limit)
 	rows, err := db.Conn.Query(
-		`SELECT type, service, latency_ms, error_rate, queue_length, extract(epoch from timestamp)*1000 as ts
+		`SELECT type, service, tenant_id, COALESCE(trace_id, ''), latency_ms, error_rate, queue_length, `+db.toMillis("timestamp")+` as ts, attributes
 		 FROM anomalies
-		 WHERE service = $1
+		 WHERE tenant_id = $1 AND service = $2`+within+filter+`
 		 ORDER BY timestamp DESC
-		 LIMIT $2`, service, limit,
+		 LIMIT $`+strconv.Itoa(len(args)), args...,
 	)
 	if err != nil {
 		return nil, err
@@ -60,57 +134,41 @@ func (db *DB) GetRecentAnomalies(service string, limit int) ([]Anomaly, error) {
 	for rows.Next() {
 		var a Anomaly
 		var ts float64
-		if err := rows.Scan(&a.Type, &a.Service, &a.LatencyMs, &a.ErrorRate, &a.QueueLength, &ts); err != nil {
+		var attributes []byte
+		if err := rows.Scan(&a.Type, &a.Service, &a.TenantID, &a.TraceID, &a.LatencyMs, &a.ErrorRate, &a.QueueLength, &ts, &attributes); err != nil {
 			return nil, err
 		}
 		a.Timestamp = int64(ts)
+		a.Attributes = attributes
 		anomalies = append(anomalies, a)
 	}
 	return anomalies, nil
 }
 
-// Fetch recent context events
-func (db *DB) GetRecentEvents(service string, limit int) ([]Event, error) {
-	rows, err := db.Conn.Query(
-		`SELECT service, extract(epoch from timestamp)*1000 as ts, latency_ms, status, queue_length
-		 FROM contexts
-		 WHERE service = $1
-		 ORDER BY timestamp DESC
-		 LIMIT $2`, service, limit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var events []Event
-	for rows.Next() {
-		var e Event
-		var ts float64
-		if err := rows.Scan(&e.Service, &ts, &e.LatencyMs, &e.Status, &e.QueueLength); err != nil {
-			return nil, err
-		}
-		e.Timestamp = int64(ts)
-		events = append(events, e)
-	}
-	return events, nil
+// Fetch a tenant's recent context events within span, optionally only
+// those matching attrs
+func (db *DB) GetRecentEvents(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error) {
+	return storage.QueryContexts(db.Conn, db.sqlite, tenant, "service", service, span, attrs, limit)
 }
 
 // DB structs
-type Event struct {
-	TraceID     string
-	Service     string
-	Timestamp   int64
-	LatencyMs   int
-	Status      string
-	QueueLength int
-}
+
+// Event is the canonical context event stored in contexts
+type Event = storage.Event
+
+// SampledEvent is a context event with the probability sampling gave it
+// of being stored
+type SampledEvent = storage.SampledEvent
 
 type Anomaly struct {
 	Type        string
 	Service     string
+	TenantID    string
+	TraceID     string
 	LatencyMs   int
 	ErrorRate   float64
 	QueueLength int
 	Timestamp   int64
+	DedupKey    string `json:"-"`
+	Attributes  json.RawMessage
 }
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// Memory is a Store held in process memory, for tests and tools with no
+// database at hand. It keeps everything until the process ends. Nothing
+// writes metric samples into it, so no metric signal ever breaches.
+type Memory struct {
+	mu        sync.RWMutex
+	contexts  []memContext
+	seen      map[contextKey]bool // like the unique (event_id, timestamp) index
+	anomalies []Anomaly
+	keys      map[string]bool // anomaly_keys: dedup key → published
+	batches   *storage.BatchMetrics
+}
+
+type contextKey struct {
+	eventID   string
+	timestamp int64
+}
+
+// memContext is a stored context, with its attributes in column form
+type memContext struct {
+	event      Event // Extra is nil
+	attributes string
+	sampleRate float64
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{seen: make(map[contextKey]bool), keys: make(map[string]bool), batches: &storage.BatchMetrics{}}
+}
+
+// SaveContext stores a context event. Events already stored under the
+// same ID and timestamp are skipped.
+func (m *Memory) SaveContext(event Event) error {
+	return m.SaveContexts([]SampledEvent{{Event: event, SampleRate: 1}})
+}
+
+// SaveContexts stores events kept with their sample rates, skipping those
+// already stored
+func (m *Memory) SaveContexts(events []SampledEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range events {
+		key := contextKey{e.EventID, e.Timestamp}
+		if m.seen[key] {
+			continue
+		}
+		m.seen[key] = true
+
+		c := memContext{event: e.Event, attributes: e.Attributes(), sampleRate: e.SampleRate}
+		c.event.TenantID = storage.TenantOf(e.TenantID)
+		c.event.Extra = nil
+		m.contexts = append(m.contexts, c)
+	}
+	return nil
+}
+
+// SaveAnomaly stores an anomaly. One carrying a DedupKey already claimed
+// is skipped.
+func (m *Memory) SaveAnomaly(a Anomaly) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a.DedupKey != "" {
+		if _, claimed := m.keys[a.DedupKey]; claimed {
+			return nil
+		}
+		m.keys[a.DedupKey] = false
+	}
+	a.TenantID = storage.TenantOf(a.TenantID)
+	a.Attributes = json.RawMessage(storage.AttributesOrEmpty(a.Attributes))
+	m.anomalies = append(m.anomalies, a)
+	return nil
+}
+
+// AnomalyPublished reports whether the anomaly with this key was marked
+// published
+func (m *Memory) AnomalyPublished(dedupKey string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.keys[dedupKey], nil
+}
+
+// MarkAnomalyPublished records that the anomaly with this key was
+// published
+func (m *Memory) MarkAnomalyPublished(dedupKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, claimed := m.keys[dedupKey]; claimed {
+		m.keys[dedupKey] = true
+	}
+	return nil
+}
+
+// NewBatchWriter returns a writer that wants flushing once it holds
+// maxSize events or its oldest event has waited maxWait
+func (m *Memory) NewBatchWriter(maxSize int, maxWait time.Duration) *BatchWriter {
+	return storage.NewBatchWriter(m, m.batches, maxSize, maxWait)
+}
+
+// BatchStats returns a snapshot of the batch flush metrics
+func (m *Memory) BatchStats() BatchStats {
+	return m.batches.Snapshot()
+}
+
+// GetRecentEvents returns a tenant's newest context events within span,
+// optionally only those matching attrs
+func (m *Memory) GetRecentEvents(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []memContext
+	for _, c := range m.contexts {
+		e := &c.event
+		if e.TenantID == tenant && e.Service == service && span.Contains(e.Timestamp) && attrs.Matches([]byte(c.attributes)) {
+			matched = append(matched, c)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].event.Timestamp > matched[j].event.Timestamp
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var events []Event
+	for _, c := range matched {
+		e := c.event
+		e.SetAttributes([]byte(c.attributes))
+		storage.SetSampleRate(&e, c.sampleRate)
+		e.SchemaVersion = schema.Version
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// GetRecentAnomalies returns a tenant's newest anomalies within span,
+// optionally only those matching attrs
+func (m *Memory) GetRecentAnomalies(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Anomaly, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var anomalies []Anomaly
+	for _, a := range m.anomalies {
+		if a.TenantID == tenant && a.Service == service && span.Contains(a.Timestamp) && attrs.Matches(a.Attributes) {
+			anomalies = append(anomalies, a)
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Timestamp > anomalies[j].Timestamp
+	})
+	if len(anomalies) > limit {
+		anomalies = anomalies[:limit]
+	}
+	return anomalies, nil
+}
+
+// PeakMetricValues finds no samples; metric samples are written by
+// contextify's remote-write receiver, into Postgres
+func (m *Memory) PeakMetricValues(tenant, service string, names []string, window time.Duration) (map[string]float64, error) {
+	return map[string]float64{}, nil
+}
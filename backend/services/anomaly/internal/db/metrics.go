@@ -0,0 +1,44 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// PeakMetricValues returns, for each named metric with samples in the last
+// window, the highest value any of the tenant's service's series reported
+func (db *DB) PeakMetricValues(tenant, service string, names []string, window time.Duration) (map[string]float64, error) {
+	query := `SELECT name, MAX(value)
+		 FROM metric_points
+		 WHERE tenant_id = $1 AND service = $2 AND name = ANY($3) AND timestamp > $4
+		 GROUP BY name`
+	args := []interface{}{storage.TenantOf(tenant), service, pq.Array(names), time.Now().Add(-window).UTC()}
+	if db.sqlite {
+		list, _ := json.Marshal(names)
+		query = `SELECT name, MAX(value)
+		 FROM metric_points
+		 WHERE tenant_id = $1 AND service = $2 AND name IN (SELECT value FROM json_each($3)) AND timestamp > $4
+		 GROUP BY name`
+		args = []interface{}{storage.TenantOf(tenant), service, string(list), time.Now().Add(-window).UnixMilli()}
+	}
+
+	rows, err := db.Conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var value float64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+	_ "modernc.org/sqlite"
+)
+
+// SQLitePrefix marks a DSN naming a local SQLite file instead of a
+// Postgres server
+const SQLitePrefix = storage.SQLitePrefix
+
+// openSQLite opens the SQLite file at path. The schema is created by
+// contextify, which dev mode opens first. Several DB handles may share
+// the file: writes wait for each other instead of failing.
+func openSQLite(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer at a time anyway
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
+
+// fromMillis is the SQL for storing the Unix-millisecond parameter n in a
+// timestamp column
+func (db *DB) fromMillis(n int) string {
+	return storage.FromMillis(n, db.sqlite)
+}
+
+// toMillis is the SQL reading a timestamp column as Unix milliseconds
+func (db *DB) toMillis(column string) string {
+	return storage.ToMillis(column, db.sqlite)
+}
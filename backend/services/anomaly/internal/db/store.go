@@ -0,0 +1,40 @@
+package db
+
+import (
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// Store is the storage the detector and the consumer work against:
+// saving contexts and anomalies, tracking which anomalies were published,
+// and querying rows back. *DB keeps them in Postgres, or SQLite in dev
+// mode; Memory keeps them in process memory. Every query is scoped to one
+// tenant, and the Get queries return the newest rows first.
+type Store interface {
+	SaveContext(event Event) error
+	SaveContexts(events []SampledEvent) error
+
+	// SaveAnomaly writes an anomaly carrying a DedupKey at most once
+	SaveAnomaly(a Anomaly) error
+	AnomalyPublished(dedupKey string) (bool, error)
+	MarkAnomalyPublished(dedupKey string) error
+
+	// NewBatchWriter returns a writer flushing into the store, whose
+	// flushes are reported by BatchStats
+	NewBatchWriter(maxSize int, maxWait time.Duration) *BatchWriter
+	BatchStats() BatchStats
+
+	GetRecentEvents(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error)
+	GetRecentAnomalies(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Anomaly, error)
+	PeakMetricValues(tenant, service string, names []string, window time.Duration) (map[string]float64, error)
+}
+
+// Both implementations satisfy Store
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*Memory)(nil)
+)
+
+// TimeRange bounds a query to rows timestamped in [From, To)
+type TimeRange = storage.TimeRange
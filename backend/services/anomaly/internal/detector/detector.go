@@ -2,27 +2,23 @@ package detector
 
 import (
 	"encoding/json"
-	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
 	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
 )
 
-type Event struct {
-	TraceID     string `json:"trace_id"`
-	Service     string `json:"service"`
-	Timestamp   int64  `json:"timestamp"`
-	LatencyMs   int    `json:"latency_ms"`
-	Status      string `json:"status"`
-	QueueLength int    `json:"queue_length"`
-}
+// Event is the canonical context event the detector evaluates
+type Event = schema.Event
 
 // ErrorRateTracker tracks error rates over a time window
 type ErrorRateTracker struct {
 	window     time.Duration
 	events     []Event
 	errorCount int
+	latest     int64 // newest event time seen, unix millis
 }
 
 // NewErrorRateTracker creates a new error rate tracker
@@ -35,7 +31,14 @@ func NewErrorRateTracker(windowSeconds int) *ErrorRateTracker {
 
 // AddEvent adds an event to the tracker
 func (t *ErrorRateTracker) AddEvent(event Event) {
-	now := time.Now()
+	// The window ends at the newest event time seen rather than the
+	// clock's, so replayed history is judged as it was when it happened,
+	// while a late event neither pushes newer ones out nor is judged
+	// against a window of its own
+	if event.Timestamp > t.latest {
+		t.latest = event.Timestamp
+	}
+	now := time.UnixMilli(t.latest)
 
 	// Remove old events outside the window
 	t.events = append(t.events, event)
@@ -67,6 +70,28 @@ func (t *ErrorRateTracker) CheckErrorRate(threshold float64) bool {
 	return errorRate > threshold
 }
 
+// Evaluate runs the built-in rules with the event's tenant's thresholds,
+// for an event already added to its service's tracker. It returns the
+// anomaly types raised and, for error_rate_spike, the error rate.
+func Evaluate(tracker *ErrorRateTracker, event Event, th Thresholds) (raised []string, errorRate float64) {
+	// Check latency spike
+	if CheckLatency(event, th.LatencyMs) {
+		raised = append(raised, "latency_spike")
+	}
+
+	// Check error rate spike
+	if tracker.CheckErrorRate(th.ErrorRate) {
+		raised = append(raised, "error_rate_spike")
+		errorRate = th.ErrorRate
+	}
+
+	// Check queue length threshold
+	if CheckQueueLength(event, th.QueueLength) {
+		raised = append(raised, "queue_length_spike")
+	}
+	return raised, errorRate
+}
+
 // CheckLatency checks if latency exceeds threshold
 func CheckLatency(event Event, thresholdMs int) bool {
 	return event.LatencyMs > thresholdMs
@@ -77,12 +102,15 @@ func CheckQueueLength(event Event, threshold int) bool {
 	return event.QueueLength > threshold
 }
 
-// CreateAnomalyMessage creates a JSON message for anomaly
-func CreateAnomalyMessage(event Event, anomalyType string) string {
+// CreateAnomalyMessage creates a JSON message for anomaly. anomalyID lets
+// downstream consumers drop the rare duplicate publish.
+func CreateAnomalyMessage(event Event, anomalyType string, anomalyID string) string {
 	anomaly := map[string]interface{}{
+		"anomaly_id":   anomalyID,
 		"type":         anomalyType,
 		"trace_id":     event.TraceID,
 		"service":      event.Service,
+		"tenant_id":    event.TenantID,
 		"timestamp":    event.Timestamp,
 		"latency_ms":   event.LatencyMs,
 		"status":       event.Status,
@@ -97,92 +125,61 @@ func CreateAnomalyMessage(event Event, anomalyType string) string {
 	return string(msg)
 }
 
+var anomalyCount int64
+
 // IncrementAnomalyCount increments the global anomaly counter
 func IncrementAnomalyCount() {
-	// This could be implemented with a global counter or metrics
-	// For now, just log it
-	logrus.Info("Anomaly count incremented")
+	atomic.AddInt64(&anomalyCount, 1)
+}
+
+// AnomalyCount returns how many anomalies were raised since startup
+func AnomalyCount() int64 {
+	return atomic.LoadInt64(&anomalyCount)
 }
 
 // ParseEvent parses JSON event from Kafka message
 func ParseEvent(data []byte) (*Event, error) {
-	var raw map[string]interface{}
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, err
-	}
-
-	e := &Event{}
+	return schema.Parse(data)
+}
 
-	if v, ok := raw["trace_id"].(string); ok {
-		e.TraceID = v
-	}
-	if v, ok := raw["service"].(string); ok {
-		e.Service = v
-	}
-	if v, ok := raw["latency_ms"].(float64); ok {
-		e.LatencyMs = int(v)
-	}
-	if v, ok := raw["status"].(string); ok {
-		e.Status = v
-	}
-	if v, ok := raw["queue_length"].(float64); ok {
-		e.QueueLength = int(v)
-	}
+// Persist anomaly to the store. dedupKey identifies the source message and
+// anomaly type; persisting the same key twice is a no-op.
+func PersistAnomaly(store db.Store, event Event, anomalyType string, errorRate float64, dedupKey string) error {
+	a := BuildAnomaly(event, anomalyType, errorRate, dedupKey)
+	a.Timestamp = time.Now().UnixMilli()
 
-	// Handle timestamp (could be number, string millis, or RFC3339 string)
-	switch ts := raw["timestamp"].(type) {
-	case float64:
-		e.Timestamp = int64(ts)
-	case string:
-		// Try parse as integer millis
-		if millis, err := strconv.ParseInt(ts, 10, 64); err == nil {
-			e.Timestamp = millis
-		} else {
-			// Try parse as RFC3339
-			if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
-				e.Timestamp = parsed.UnixMilli()
-			} else {
-				return nil, err
-			}
-		}
+	if err := store.SaveAnomaly(a); err != nil {
+		logrus.Errorf("Failed to persist anomaly: %v", err)
+		return err
 	}
-
-	return e, nil
+	return nil
 }
 
-// Persist anomaly to DB
-func PersistAnomaly(dbConn *db.DB, event Event, anomalyType string, errorRate float64) {
-	a := db.Anomaly{
+// BuildAnomaly describes an anomaly raised by event, timestamped with the
+// event's time
+func BuildAnomaly(event Event, anomalyType string, errorRate float64, dedupKey string) db.Anomaly {
+	return db.Anomaly{
 		Type:        anomalyType,
 		Service:     event.Service,
+		TenantID:    event.TenantID,
+		TraceID:     event.TraceID,
 		LatencyMs:   event.LatencyMs,
 		ErrorRate:   errorRate,
 		QueueLength: event.QueueLength,
-		Timestamp:   time.Now().UnixMilli(),
-	}
-
-	if err := dbConn.SaveAnomaly(a); err != nil {
-		logrus.Errorf("Failed to persist anomaly: %v", err)
+		Timestamp:   event.Timestamp,
+		DedupKey:    dedupKey,
+		Attributes:  json.RawMessage(event.Attributes()),
 	}
 }
 
-// Fetch last N events for a service
-func AttachRecentContext(dbConn *db.DB, service string, limit int) ([]Event, error) {
-	dbEvents, err := dbConn.GetRecentEvents(service, limit)
+// Fetch last N events for a tenant's service within span, optionally only
+// those matching attrs
+func AttachRecentContext(store db.Store, tenant, service string, span db.TimeRange, attrs db.AttrFilter, limit int) ([]Event, error) {
+	dbEvents, err := store.GetRecentEvents(tenant, service, span, attrs, limit)
 	if err != nil {
 		logrus.Errorf("Failed to fetch recent context: %v", err)
 		return nil, err
 	}
 
-	events := make([]Event, len(dbEvents))
-	for i, dbEvent := range dbEvents {
-		events[i] = Event{
-			Service:     dbEvent.Service,
-			Timestamp:   dbEvent.Timestamp,
-			LatencyMs:   dbEvent.LatencyMs,
-			Status:      dbEvent.Status,
-			QueueLength: dbEvent.QueueLength,
-		}
-	}
-	return events, nil
+	return dbEvents, nil
 }
@@ -0,0 +1,46 @@
+package detector
+
+import "testing"
+
+func TestErrorRateTrackerWindow(t *testing.T) {
+	const second = int64(1000)
+	tests := []struct {
+		name   string
+		events []Event
+		want   bool // error rate above 0.5
+	}{
+		{"recent errors", []Event{
+			{Status: "success", Timestamp: 0},
+			{Status: "error", Timestamp: 1 * second},
+			{Status: "error", Timestamp: 2 * second},
+		}, true},
+		{"errors aged out", []Event{
+			{Status: "error", Timestamp: 0},
+			{Status: "error", Timestamp: 1 * second},
+			{Status: "success", Timestamp: 20 * second},
+		}, false},
+		// A late event is judged against the newest time seen, and does
+		// not push the newer events out of the window
+		{"late event keeps newer ones", []Event{
+			{Status: "error", Timestamp: 20 * second},
+			{Status: "error", Timestamp: 21 * second},
+			{Status: "success", Timestamp: 15 * second},
+		}, true},
+		{"very late event dropped", []Event{
+			{Status: "success", Timestamp: 20 * second},
+			{Status: "error", Timestamp: 0},
+			{Status: "error", Timestamp: 1 * second},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewErrorRateTracker(10)
+			for _, e := range tt.events {
+				tracker.AddEvent(e)
+			}
+			if got := tracker.CheckErrorRate(0.5); got != tt.want {
+				t.Errorf("CheckErrorRate(0.5) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package detector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/db"
+)
+
+// MetricThreshold flags a service whose peak value of a Prometheus metric
+// goes above Max
+type MetricThreshold struct {
+	Name string
+	Max  float64
+}
+
+// MetricBreach is a threshold a service currently exceeds
+type MetricBreach struct {
+	Name  string
+	Value float64
+	Max   float64
+}
+
+// ParseMetricThresholds reads "name>max,...", e.g.
+// "process_cpu_usage>0.9,queue_depth>500"
+func ParseMetricThresholds(s string) ([]MetricThreshold, error) {
+	var thresholds []MetricThreshold
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, max, ok := strings.Cut(part, ">")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("metric threshold %q: expected name>max", part)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(max), 64)
+		if err != nil {
+			return nil, fmt.Errorf("metric threshold %q: %w", part, err)
+		}
+		thresholds = append(thresholds, MetricThreshold{Name: strings.TrimSpace(name), Max: v})
+	}
+	return thresholds, nil
+}
+
+// MetricSignals checks services' recent metric points against thresholds.
+// Each tenant's service's values are cached for the refresh interval, so
+// checking every event doesn't cost a query per event. It is safe for
+// concurrent use; the consumer's workers share one.
+type MetricSignals struct {
+	store      db.Store
+	thresholds []MetricThreshold
+	names      []string
+	window     time.Duration
+	refresh    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]metricSnapshot
+}
+
+type metricSnapshot struct {
+	values  map[string]float64
+	fetched time.Time
+}
+
+func NewMetricSignals(store db.Store, thresholds []MetricThreshold, window, refresh time.Duration) *MetricSignals {
+	names := make([]string, len(thresholds))
+	for i, t := range thresholds {
+		names[i] = t.Name
+	}
+	return &MetricSignals{
+		store:      store,
+		thresholds: thresholds,
+		names:      names,
+		window:     window,
+		refresh:    refresh,
+		cache:      make(map[string]metricSnapshot),
+	}
+}
+
+// Breaches returns the thresholds the tenant's service's metrics exceed
+// over the last window. Lookup failures are logged and treated as no
+// signal.
+func (m *MetricSignals) Breaches(tenant, service string) []MetricBreach {
+	if len(m.thresholds) == 0 {
+		return nil
+	}
+
+	key := tenant + "/" + service
+	m.mu.Lock()
+	snap, ok := m.cache[key]
+	m.mu.Unlock()
+
+	// Queried outside the lock: a service's events all run on one worker,
+	// so two workers never refresh the same key
+	if !ok || time.Since(snap.fetched) >= m.refresh {
+		values, err := m.store.PeakMetricValues(tenant, service, m.names, m.window)
+		if err != nil {
+			logrus.Warnf("Failed to read metrics for %s: %v", service, err)
+			return nil
+		}
+		snap = metricSnapshot{values: values, fetched: time.Now()}
+		m.mu.Lock()
+		m.cache[key] = snap
+		m.mu.Unlock()
+	}
+
+	var breaches []MetricBreach
+	for _, t := range m.thresholds {
+		if v, ok := snap.values[t.Name]; ok && v > t.Max {
+			breaches = append(breaches, MetricBreach{Name: t.Name, Value: v, Max: t.Max})
+		}
+	}
+	return breaches
+}
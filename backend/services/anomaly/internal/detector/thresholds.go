@@ -0,0 +1,126 @@
+package detector
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Thresholds are the limits the built-in rules flag an event beyond
+type Thresholds struct {
+	LatencyMs   int     `json:"latency_ms"`
+	ErrorRate   float64 `json:"error_rate"`
+	QueueLength int     `json:"queue_length"`
+}
+
+// DefaultThresholds returns the thresholds used unless configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{LatencyMs: 300, ErrorRate: 0.5, QueueLength: 100}
+}
+
+// TenantThresholds holds the thresholds of each tenant configured, and
+// those of every other tenant
+type TenantThresholds struct {
+	Default Thresholds            `json:"default"`
+	Tenants map[string]Thresholds `json:"tenants"`
+}
+
+// For returns the thresholds a tenant's events are held to
+func (t TenantThresholds) For(tenant string) Thresholds {
+	if th, ok := t.Tenants[tenant]; ok {
+		return th
+	}
+	return t.Default
+}
+
+// ParseTenantThresholds reads comma-separated
+// "tenant=latency_ms[:error_rate[:queue_length]]" entries, e.g.
+//
+//	payments=500:0.2,search=::1000
+//
+// An empty side keeps fallback's value.
+func ParseTenantThresholds(s string, fallback Thresholds) (map[string]Thresholds, error) {
+	tenants := make(map[string]Thresholds)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, spec, ok := strings.Cut(entry, "=")
+		tenant = strings.TrimSpace(tenant)
+		if !ok || tenant == "" {
+			return nil, fmt.Errorf("thresholds %q: want tenant=latency_ms[:error_rate[:queue_length]]", entry)
+		}
+
+		th := fallback
+		parts := strings.Split(spec, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("thresholds %q: too many values", entry)
+		}
+		for i, part := range parts {
+			v := strings.TrimSpace(part)
+			if v == "" {
+				continue
+			}
+			switch i {
+			case 0:
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("thresholds %q: invalid latency_ms", entry)
+				}
+				th.LatencyMs = n
+			case 1:
+				r, err := strconv.ParseFloat(v, 64)
+				if err != nil || r < 0 || r > 1 {
+					return nil, fmt.Errorf("thresholds %q: invalid error_rate", entry)
+				}
+				th.ErrorRate = r
+			case 2:
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("thresholds %q: invalid queue_length", entry)
+				}
+				th.QueueLength = n
+			}
+		}
+		tenants[tenant] = th
+	}
+	return tenants, nil
+}
+
+// ThresholdsFromEnv reads THRESHOLD_LATENCY_MS, THRESHOLD_ERROR_RATE and
+// THRESHOLD_QUEUE_LENGTH over the defaults, and TENANT_THRESHOLDS (see
+// ParseTenantThresholds) over those
+func ThresholdsFromEnv() (TenantThresholds, error) {
+	t := TenantThresholds{Default: DefaultThresholds()}
+
+	if v := os.Getenv("THRESHOLD_LATENCY_MS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return t, fmt.Errorf("invalid THRESHOLD_LATENCY_MS %q", v)
+		}
+		t.Default.LatencyMs = n
+	}
+	if v := os.Getenv("THRESHOLD_ERROR_RATE"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return t, fmt.Errorf("invalid THRESHOLD_ERROR_RATE %q", v)
+		}
+		t.Default.ErrorRate = r
+	}
+	if v := os.Getenv("THRESHOLD_QUEUE_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return t, fmt.Errorf("invalid THRESHOLD_QUEUE_LENGTH %q", v)
+		}
+		t.Default.QueueLength = n
+	}
+
+	tenants, err := ParseTenantThresholds(os.Getenv("TENANT_THRESHOLDS"), t.Default)
+	if err != nil {
+		return t, fmt.Errorf("invalid TENANT_THRESHOLDS: %w", err)
+	}
+	t.Tenants = tenants
+	return t, nil
+}
@@ -0,0 +1,69 @@
+package detector
+
+import "testing"
+
+func TestParseTenantThresholds(t *testing.T) {
+	fallback := DefaultThresholds()
+	tests := []struct {
+		in      string
+		want    map[string]Thresholds
+		wantErr bool
+	}{
+		{in: "", want: map[string]Thresholds{}},
+		{in: "payments=500:0.2:50", want: map[string]Thresholds{"payments": {500, 0.2, 50}}},
+		{in: "search=::1000, ops=100", want: map[string]Thresholds{
+			"search": {fallback.LatencyMs, fallback.ErrorRate, 1000},
+			"ops":    {100, fallback.ErrorRate, fallback.QueueLength},
+		}},
+		{in: "payments", wantErr: true},
+		{in: "=100", wantErr: true},
+		{in: "payments=-1", wantErr: true},
+		{in: "payments=:1.5", wantErr: true},
+		{in: "payments=1:0.1:1:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTenantThresholds(tt.in, fallback)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTenantThresholds(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTenantThresholds(%q): %v", tt.in, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseTenantThresholds(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for tenant, want := range tt.want {
+			if got[tenant] != want {
+				t.Errorf("ParseTenantThresholds(%q)[%s] = %+v, want %+v", tt.in, tenant, got[tenant], want)
+			}
+		}
+	}
+}
+
+func TestEvaluateUsesTenantThresholds(t *testing.T) {
+	limits := TenantThresholds{
+		Default: DefaultThresholds(),
+		Tenants: map[string]Thresholds{"payments": {LatencyMs: 1000, ErrorRate: 0.5, QueueLength: 100}},
+	}
+	event := Event{Service: "checkout", Status: "success", LatencyMs: 500, Timestamp: 1}
+
+	for _, tt := range []struct {
+		tenant string
+		want   int
+	}{
+		{"default", 1},
+		{"payments", 0},
+	} {
+		tracker := NewErrorRateTracker(10)
+		tracker.AddEvent(event)
+		raised, _ := Evaluate(tracker, event, limits.For(tt.tenant))
+		if len(raised) != tt.want {
+			t.Errorf("%s: raised %v, want %d anomalies", tt.tenant, raised, tt.want)
+		}
+	}
+}
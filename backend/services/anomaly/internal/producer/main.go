@@ -2,24 +2,18 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/segmentio/kafka-go"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/simulator"
 )
 
-type Event struct {
-	TraceID   string `json:"trace_id"`
-	Service   string `json:"service"`
-	Level     string `json:"level"`
-	Message   string `json:"message"`
-	Timestamp string `json:"timestamp"`
-}
-
 func main() {
 	// Get Kafka configuration from environment
 	brokers := os.Getenv("KAFKA_BROKERS")
@@ -32,54 +26,23 @@ func main() {
 		topic = "contextify-events"
 	}
 
-	// Create Kafka writer
-	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  []string{brokers},
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
-	})
-	defer writer.Close()
+	// application/json (default), application/x-protobuf or application/avro
+	encoding, err := schema.NormalizeContentType(os.Getenv("EVENT_ENCODING"))
+	if err != nil {
+		log.Fatalf("Invalid EVENT_ENCODING: %v", err)
+	}
+
+	// Create Kafka publisher
+	publisher := producer.NewPublisher(strings.Split(brokers, ","), topic, producer.WithEncoding(encoding))
+	defer publisher.Close()
 
 	// Graceful shutdown
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("🚀 Anomaly producer starting, publishing to topic: %s", topic)
+	log.Printf("🚀 Anomaly producer starting, publishing %s to topic: %s", encoding, topic)
 
 	// Simulate producing events
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			event := Event{
-				TraceID:   "test-trace-" + time.Now().Format("20060102150405"),
-				Service:   "anomaly-service",
-				Level:     "INFO",
-				Message:   "Test event from anomaly producer",
-				Timestamp: time.Now().Format(time.RFC3339),
-			}
-
-			bytes, err := json.Marshal(event)
-			if err != nil {
-				log.Printf("Failed to marshal event: %v", err)
-				continue
-			}
-
-			err = writer.WriteMessages(context.Background(), kafka.Message{
-				Key:   []byte(event.TraceID),
-				Value: bytes,
-			})
-			if err != nil {
-				log.Printf("Failed to publish event: %v", err)
-			} else {
-				log.Printf("�� Published event: %s", event.TraceID)
-			}
-
-		case <-signals:
-			log.Println("🛑 Anomaly producer shutting down...")
-			return
-		}
-	}
+	simulator.Run(ctx, publisher, 5*time.Second)
+	log.Println("🛑 Anomaly producer shutting down...")
 }
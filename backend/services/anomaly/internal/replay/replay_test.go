@@ -0,0 +1,90 @@
+package replay
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/anomaly/internal/detector"
+)
+
+func TestRanges(t *testing.T) {
+	ctx := context.Background()
+	b := bus.NewMemory(2)
+	defer b.Close()
+
+	// Unkeyed messages alternate partitions: partition 0 holds minutes 0,
+	// 2 and 4 at offsets 0-2, partition 1 minutes 1, 3 and 5
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	minute := func(n int) *time.Time {
+		at := t0.Add(time.Duration(n) * time.Minute)
+		return &at
+	}
+	for i := 0; i < 6; i++ {
+		if err := b.Publish(ctx, "events", bus.Message{Value: []byte("{}"), Time: *minute(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name    string
+		req     Request
+		want    []partitionRange
+		wantErr bool
+	}{
+		{"everything", Request{}, []partitionRange{{0, 0, 3}, {1, 0, 3}}, false},
+		{"one partition", Request{Partitions: []int{1}}, []partitionRange{{1, 0, 3}}, false},
+		{"from a time", Request{From: minute(2)}, []partitionRange{{0, 1, 3}, {1, 1, 3}}, false},
+		{"from between messages", Request{From: minute(3)}, []partitionRange{{0, 2, 3}, {1, 1, 3}}, false},
+		{"from after the last", Request{From: minute(6)}, nil, false},
+		{"offset range", Request{StartOffset: offset(1), EndOffset: offset(2)}, []partitionRange{{0, 1, 2}, {1, 1, 2}}, false},
+		{"end past the high-water mark", Request{EndOffset: offset(10)}, []partitionRange{{0, 0, 3}, {1, 0, 3}}, false},
+		{"start past the end", Request{StartOffset: offset(5)}, nil, false},
+		{"unknown partition", Request{Partitions: []int{7}}, nil, true},
+	}
+	r := New(b, "events", nil, schema.Rules{}, detector.TenantThresholds{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.ranges(ctx, tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ranges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ranges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Now()
+	offset := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name    string
+		req     Request
+		wantErr bool
+	}{
+		{"defaults", Request{}, false},
+		{"live target", Request{Target: TargetLive}, false},
+		{"unknown target", Request{Target: "elsewhere"}, true},
+		{"from and start offset", Request{From: &at, StartOffset: offset(1)}, true},
+		{"empty offset range", Request{StartOffset: offset(5), EndOffset: offset(5)}, true},
+		{"offset range", Request{StartOffset: offset(5), EndOffset: offset(6)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if err := validate(&req); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.req.Target == "" && req.Target != TargetScratch {
+				t.Errorf("target defaulted to %q, want %q", req.Target, TargetScratch)
+			}
+		})
+	}
+}
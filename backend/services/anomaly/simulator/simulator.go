@@ -0,0 +1,37 @@
+// Package simulator publishes synthetic events, standing in for real
+// services when trying the pipeline out
+package simulator
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// Run publishes a test event every interval until ctx is cancelled
+func Run(ctx context.Context, publisher *producer.Publisher, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			event := schema.NewEvent("anomaly-service")
+			event.TraceID = "test-trace-" + time.Now().Format("20060102150405")
+			event.Status = "INFO"
+			event.Message = "Test event from anomaly producer"
+
+			if err := publisher.Publish(ctx, event); err != nil {
+				log.Printf("Failed to publish event: %v", err)
+			} else {
+				log.Printf("�� Published event: %s", event.TraceID)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -5,22 +5,37 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/config"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/consumer"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/dlq"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/grpcserver"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/handlers"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/logsource"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/otlp"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/remotewrite"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/retention"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/rollup"
 )
 
 func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.Info("🚀 Contextify service starting...")
 
+	// `contextify dev` runs the whole pipeline in this one process
+	if len(os.Args) > 1 && os.Args[1] == "dev" {
+		os.Exit(runDev(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -35,8 +50,68 @@ func main() {
 
 	defer database.Conn.Close()
 
+	// `contextify migrate ...` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(database, os.Args[2:]))
+	}
+
+	// Bring the schema up to date; the anomaly service may be doing the
+	// same, the migration lock makes one of them wait
+	applied, err := database.Migrate(context.Background())
+	if err != nil {
+		logrus.Fatalf("Failed to migrate DB: %v", err)
+	}
+	for _, m := range applied {
+		logrus.Infof("⬆️ Applied migration %03d_%s", m.Version, m.Name)
+	}
+
+	// Message bus shared by the consumer and every publisher
+	eventBus := bus.NewKafka(strings.Split(cfg.KafkaBrokers, ","))
+	defer eventBus.Close()
+
+	serve(cfg, database, eventBus)
+}
+
+// store is what serve needs of the database: *db.DB, or db.Memory
+type store interface {
+	db.Store
+	db.MetricStore
+	db.RollupStore
+	db.RetentionStore
+}
+
+// serve runs the consumer, the ingestion sources and the HTTP and gRPC
+// APIs until the process is interrupted
+func serve(cfg *config.Config, database store, eventBus bus.Bus) {
+	// Ends on interrupt, starting the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Consumer progress, shared by the consumer and /ingest/status
+	ingest := pipeline.NewIngestStatus()
+
+	// Events received over HTTP, gRPC, OTLP or from logs join the
+	// pipeline through the events topic
+	eventPublisher := producer.New(eventBus, cfg.KafkaTopic)
+
+	// Per-service ingestion limits, shared by every ingest path
+	quotas := pipeline.NewQuotas(cfg.Quotas, cfg.QuotaDefault, cfg.QuotaExcessSampleRate)
+	receiver := otlp.NewReceiver(eventPublisher, cfg.Validation, quotas)
+
+	// Head sampling of stored contexts, per service
+	sampler := cfg.Sampling.NewSampler(cfg.DedupMaxEntries)
+
+	// Dead-letter topic inspector for the admin API
+	inspector := dlq.NewInspector(eventBus, cfg.DLQTopic)
+
 	// Initialize Handlers with DB
-	h := handlers.NewHandlers(database)
+	h := handlers.NewHandlers(database, inspector, ingest, cfg.LagWarningThreshold, handlers.EventIngest{
+		Publisher:    eventPublisher,
+		Rules:        cfg.Validation,
+		MaxBatch:     cfg.IngestMaxBatch,
+		MaxBodyBytes: cfg.IngestMaxBodyBytes,
+		Quotas:       quotas,
+	}, sampler, cfg.RetentionDays)
 
 	// Create router
 	router := mux.NewRouter()
@@ -45,28 +120,130 @@ func main() {
 	router.Use(loggingMiddleware)
 	router.Use(corsMiddleware)
 
+	// Every API but the health check acts for the tenant of the caller's
+	// API key
+	router.Use(cfg.TenantKeys.Middleware("/health"))
+
 	// Health check endpoint
 	router.HandleFunc("/health", handlers.HealthHandler).Methods("GET")
 
 	// Incident management endpoints
-	router.HandleFunc("/incident/start", handlers.StartIncidentHandler).Methods("POST")
-	router.HandleFunc("/incident/stop", handlers.StopIncidentHandler).Methods("POST")
+	router.HandleFunc("/incident/start", h.StartIncidentHandler).Methods("POST")
+	router.HandleFunc("/incident/stop", h.StopIncidentHandler).Methods("POST")
 
-	// Metrics endpoint
-	router.HandleFunc("/metrics", handlers.MetricsHandler).Methods("GET")
+	// Metrics endpoints
+	router.HandleFunc("/metrics", h.MetricsHandler).Methods("GET")
+	router.HandleFunc("/ingest/status", h.IngestStatusHandler).Methods("GET")
+	router.HandleFunc("/quotas", h.QuotasHandler).Methods("GET")
 
 	router.HandleFunc("/context/{trace_id}", h.GetContextByTraceID).Methods("GET")
 	router.HandleFunc("/anomalies", h.GetAnomaliesByService).Methods("GET")
+	router.HandleFunc("/series", h.GetSeries).Methods("GET")
+
+	// HTTP event ingestion for producers without Kafka access
+	router.HandleFunc("/events", h.IngestEvents).Methods("POST")
+
+	// OTLP/HTTP trace ingestion
+	router.HandleFunc("/v1/traces", receiver.HandleHTTP).Methods("POST")
 
-	// Start Kafka consumer with DB reference
+	// Prometheus remote write
+	selectors, err := remotewrite.ParseSelectors(cfg.RemoteWriteMatchers)
+	if err != nil {
+		logrus.Fatalf("Invalid REMOTE_WRITE_MATCHERS: %v", err)
+	}
+	if len(selectors) == 0 {
+		logrus.Warn("📈 REMOTE_WRITE_MATCHERS is empty; remote write samples are accepted but not stored")
+	}
+	metrics := remotewrite.NewReceiver(database, selectors, cfg.RemoteWriteServiceLabels)
+	router.HandleFunc("/api/v1/write", metrics.HandleHTTP).Methods("POST")
+
+	// Dead-letter admin endpoints
+	router.HandleFunc("/admin/dlq", h.ListDeadLetters).Methods("GET")
+	router.HandleFunc("/admin/dlq/redrive", h.RedriveDeadLetters).Methods("POST")
+
+	// Partition sizes of the time-partitioned tables
+	router.HandleFunc("/admin/partitions", h.ListPartitions).Methods("GET")
+
+	// Join the consumer group with DB reference
+	consumerDone := make(chan struct{})
 	go func() {
-		if err := consumer.Start(cfg.KafkaBrokers, "contextify-events", database); err != nil {
-			logrus.Fatalf("Kafka consumer failed: %v", err)
+		defer close(consumerDone)
+		consumerCfg := consumer.Config{
+			Topic:             cfg.KafkaTopic,
+			GroupID:           cfg.KafkaGroupID,
+			InitialOffset:     cfg.KafkaInitialOffset,
+			RebalanceStrategy: cfg.KafkaRebalanceStrategy,
+			DLQTopic:          cfg.DLQTopic,
+			Rules:             cfg.Validation,
+			BatchSize:         cfg.BatchSize,
+			FlushInterval:     cfg.BatchFlushInterval,
+			DedupWindow:       cfg.DedupWindow,
+			DedupMaxEntries:   cfg.DedupMaxEntries,
+			Workers:           cfg.Workers,
+			WorkerQueueSize:   cfg.WorkerQueueSize,
+			Sampler:           sampler,
+		}
+		if err := consumer.Start(ctx, consumerCfg, eventBus, database, ingest); err != nil {
+			logrus.Fatalf("Consumer failed: %v", err)
 		}
 	}()
 
+	// Background work stopped on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Warn about the pipeline itself when the consumer falls behind
+	go consumer.WatchLag(bgCtx, ingest, database, cfg.LagWarningThreshold, cfg.LagCheckInterval, 5*time.Minute)
+
+	// Daily partitions ahead of time, and old ones dropped
+	go retention.Run(bgCtx, database, retention.Config{
+		Days:        cfg.RetentionDays,
+		PremakeDays: cfg.PartitionPremakeDays,
+		Interval:    cfg.RetentionInterval,
+	})
+
+	// 1-minute and 1-hour aggregates behind /series
+	go rollup.Run(bgCtx, database, rollup.Config{
+		Interval: cfg.RollupInterval,
+		Lateness: cfg.RollupLateness,
+		Days:     cfg.RetentionDays,
+	})
+
+	// Syslog and log file sources
+	if cfg.SyslogUDPAddr != "" || cfg.SyslogTCPAddr != "" || len(cfg.LogTailFiles) > 0 {
+		mapping, err := logsource.NewMapping(cfg.LogRegex, cfg.LogJSONFields)
+		if err != nil {
+			logrus.Fatalf("Invalid log mapping: %v", err)
+		}
+		sink := logsource.NewSink(eventPublisher, cfg.Validation, quotas)
+		go sink.Run(bgCtx, 500*time.Millisecond, 500)
+
+		if cfg.SyslogUDPAddr != "" {
+			go func() {
+				if err := logsource.ListenSyslogUDP(bgCtx, cfg.SyslogUDPAddr, mapping, sink); err != nil {
+					logrus.Fatalf("Syslog UDP listener failed: %v", err)
+				}
+			}()
+		}
+		if cfg.SyslogTCPAddr != "" {
+			go func() {
+				if err := logsource.ListenSyslogTCP(bgCtx, cfg.SyslogTCPAddr, mapping, sink); err != nil {
+					logrus.Fatalf("Syslog TCP listener failed: %v", err)
+				}
+			}()
+		}
+		for _, path := range cfg.LogTailFiles {
+			go logsource.TailFile(bgCtx, path, cfg.LogTailPollInterval, mapping, sink)
+		}
+	}
+
 	// Start gRPC server
-	grpcShutdown := grpcserver.StartGrpcServer(cfg.GrpcPort)
+	grpcShutdown := grpcserver.StartGrpcServer(cfg.GrpcPort, grpcserver.Ingest{
+		Publisher: eventPublisher,
+		Rules:     cfg.Validation,
+		MaxBatch:  cfg.IngestMaxBatch,
+		Quotas:    quotas,
+	}, receiver, cfg.TenantKeys)
 	defer grpcShutdown()
 
 	// Create HTTP server
@@ -87,20 +264,21 @@ func main() {
 	}()
 
 	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
 
 	logrus.Info("🛑 Shutting down Contextify service...")
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logrus.Errorf("HTTP server forced to shutdown: %v", err)
 	}
 
+	// The consumer flushes and acks what it holds before returning
+	<-consumerDone
+
 	logrus.Info("✅ Contextify service stopped gracefully")
 }
 
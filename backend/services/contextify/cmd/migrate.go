@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/migrate"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+// runMigrateCommand handles `contextify migrate up|down|status`: applies or
+// reverts schema migrations, or lists them, and returns the process exit
+// code
+func runMigrateCommand(dbConn *db.DB, args []string) int {
+	m, err := dbConn.Migrator()
+	if err != nil {
+		logrus.Errorf("Cannot migrate: %v", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = migrate.Command(ctx, m, args, os.Stdout, logrus.Infof)
+	switch {
+	case errors.Is(err, migrate.ErrUsage):
+		logrus.Errorf("%v (usage: contextify migrate up|down [-steps N]|status)", err)
+		return 2
+	case err != nil:
+		logrus.Error(err)
+		return 1
+	}
+	return 0
+}
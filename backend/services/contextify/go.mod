@@ -3,39 +3,63 @@ module github.com/sujal-lgtm/Contextify/backend/services/contextify
 go 1.23.0
 
 require (
-	github.com/Shopify/sarama v1.38.1
+	github.com/golang/snappy v0.0.4
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	github.com/sirupsen/logrus v1.9.3
+	github.com/sujal-lgtm/Contextify/backend/pkg/bus v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/migrate v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/pipeline v0.0.0
 	github.com/sujal-lgtm/Contextify/backend/pkg/producer v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/schema v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/pkg/tenant v0.0.0
+	github.com/sujal-lgtm/Contextify/backend/services/anomaly v0.0.0
+	go.opentelemetry.io/proto/otlp v1.7.0
 	google.golang.org/grpc v1.75.0
 	google.golang.org/protobuf v1.36.6
+	modernc.org/sqlite v1.39.0
 )
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/eapache/go-resiliency v1.3.0 // indirect
-	github.com/eapache/go-xerial-snappy v0.0.0-20230111030713-bf00bc1b83b6 // indirect
-	github.com/eapache/queue v1.1.0 // indirect
-	github.com/golang/snappy v0.0.4 // indirect
-	github.com/hashicorp/errwrap v1.0.0 // indirect
-	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/hashicorp/go-uuid v1.0.3 // indirect
-	github.com/jcmturner/aescts/v2 v2.0.0 // indirect
-	github.com/jcmturner/dnsutils/v2 v2.0.0 // indirect
-	github.com/jcmturner/gofork v1.7.6 // indirect
-	github.com/jcmturner/gokrb5/v8 v8.4.3 // indirect
-	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
-	github.com/klauspost/compress v1.15.14 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.26.3 // indirect
+	github.com/hamba/avro/v2 v2.27.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/klauspost/compress v1.17.10 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/pierrec/lz4/v4 v4.1.17 // indirect
-	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/segmentio/kafka-go v0.4.49 // indirect
-	golang.org/x/crypto v0.39.0 // indirect
+	github.com/sujal-lgtm/Contextify/backend/pkg/storage v0.0.0
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/net v0.41.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
 )
 
+replace github.com/sujal-lgtm/Contextify/backend/pkg/bus => ../../pkg/bus
+
+replace github.com/sujal-lgtm/Contextify/backend/services/anomaly => ../anomaly
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/pipeline => ../../pkg/pipeline
+
 replace github.com/sujal-lgtm/Contextify/backend/pkg/producer => ../../pkg/producer
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/schema => ../../pkg/schema
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/tenant => ../../pkg/tenant
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/migrate => ../../pkg/migrate
+
+replace github.com/sujal-lgtm/Contextify/backend/pkg/storage => ../../pkg/storage
@@ -1,17 +1,58 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
 )
 
 type Config struct {
-	RestPort     string
-	GrpcPort     string
-	KafkaBrokers string
-	DatabaseURL  string
+	RestPort                 string
+	GrpcPort                 string
+	KafkaBrokers             string
+	KafkaTopic               string
+	KafkaGroupID             string
+	KafkaInitialOffset       string
+	KafkaRebalanceStrategy   string
+	DLQTopic                 string
+	DatabaseURL              string
+	Validation               schema.Rules
+	BatchSize                int
+	BatchFlushInterval       time.Duration
+	DedupWindow              time.Duration
+	DedupMaxEntries          int
+	Workers                  int
+	WorkerQueueSize          int
+	LagWarningThreshold      int64
+	LagCheckInterval         time.Duration
+	IngestMaxBatch           int
+	IngestMaxBodyBytes       int64
+	SyslogUDPAddr            string
+	SyslogTCPAddr            string
+	LogTailFiles             []string
+	LogTailPollInterval      time.Duration
+	LogRegex                 string
+	LogJSONFields            string
+	RemoteWriteMatchers      string
+	RemoteWriteServiceLabels []string
+	Sampling                 pipeline.SamplingConfig
+	Quotas                   map[string]pipeline.QuotaLimit
+	QuotaDefault             pipeline.QuotaLimit
+	QuotaExcessSampleRate    float64
+	TenantKeys               tenant.Keys
+	RetentionDays            map[string]int // per table; 0 keeps everything
+	PartitionPremakeDays     int
+	RetentionInterval        time.Duration
+	RollupInterval           time.Duration
+	RollupLateness           time.Duration
 }
 
 func LoadConfig() (*Config, error) {
@@ -36,6 +77,236 @@ func LoadConfig() (*Config, error) {
 		kafkaBrokers = "kafka:9092"
 	}
 
+	kafkaTopic := os.Getenv("KAFKA_TOPIC")
+	if kafkaTopic == "" {
+		kafkaTopic = "contextify-events"
+	}
+
+	kafkaGroupID := os.Getenv("KAFKA_GROUP_ID")
+	if kafkaGroupID == "" {
+		// replicas sharing this group split the topic's partitions
+		kafkaGroupID = "contextify-service"
+	}
+
+	// only used when the group has no committed offset yet: "oldest" or "newest"
+	kafkaInitialOffset := os.Getenv("KAFKA_INITIAL_OFFSET")
+	if kafkaInitialOffset == "" {
+		kafkaInitialOffset = "newest"
+	}
+
+	// "range" or "roundrobin". "sticky", accepted before the move off
+	// sarama, is refused: the Kafka client has no sticky assignor
+	kafkaRebalanceStrategy := os.Getenv("KAFKA_REBALANCE_STRATEGY")
+	switch strings.ToLower(kafkaRebalanceStrategy) {
+	case "":
+		kafkaRebalanceStrategy = "range"
+	case "range", "roundrobin":
+	default:
+		return nil, fmt.Errorf("invalid KAFKA_REBALANCE_STRATEGY %q (want range or roundrobin; sticky is not supported)", kafkaRebalanceStrategy)
+	}
+
+	dlqTopic := os.Getenv("DLQ_TOPIC")
+	if dlqTopic == "" {
+		dlqTopic = kafkaTopic + "-dlq"
+	}
+
+	// VALIDATION_* variables override the default schema rules
+	validation, err := schema.RulesFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// context events are written in batches of up to BATCH_SIZE rows...
+	batchSize, err := pipeline.EnvInt("BATCH_SIZE", 500)
+	if err != nil {
+		return nil, err
+	}
+
+	// ...or whatever has accumulated after BATCH_FLUSH_INTERVAL
+	batchFlushInterval, err := pipeline.EnvDuration("BATCH_FLUSH_INTERVAL", 500*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+
+	// event IDs seen within DEDUP_WINDOW are dropped as redeliveries
+	dedupWindow, err := pipeline.EnvDuration("DEDUP_WINDOW", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	dedupMaxEntries, err := pipeline.EnvInt("DEDUP_MAX_ENTRIES", 100000)
+	if err != nil {
+		return nil, err
+	}
+
+	// events are spread over WORKERS goroutines by service; each buffers
+	// up to WORKER_QUEUE_SIZE events before the Kafka reader blocks
+	workers, err := pipeline.EnvInt("WORKERS", 8)
+	if err != nil {
+		return nil, err
+	}
+
+	workerQueueSize, err := pipeline.EnvInt("WORKER_QUEUE_SIZE", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	// a partition more than LAG_WARNING_THRESHOLD messages behind raises
+	// a consumer_lag anomaly, checked every LAG_CHECK_INTERVAL
+	lagWarningThreshold := int64(10000)
+	if v := os.Getenv("LAG_WARNING_THRESHOLD"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid LAG_WARNING_THRESHOLD %q", v)
+		}
+		lagWarningThreshold = n
+	}
+
+	lagCheckInterval, err := pipeline.EnvDuration("LAG_CHECK_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// POST /events limits: events per request, and request body size
+	// after decompression
+	ingestMaxBatch, err := pipeline.EnvInt("INGEST_MAX_BATCH", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	ingestMaxBodyBytes := int64(10 << 20)
+	if v := os.Getenv("INGEST_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid INGEST_MAX_BODY_BYTES %q", v)
+		}
+		ingestMaxBodyBytes = n
+	}
+
+	// Log sources are off unless an address or file list is given
+	var logTailFiles []string
+	for _, path := range strings.Split(os.Getenv("LOG_TAIL_FILES"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			logTailFiles = append(logTailFiles, path)
+		}
+	}
+
+	logTailPollInterval, err := pipeline.EnvDuration("LOG_TAIL_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Labels naming a remote-write series' service, first present wins
+	remoteWriteServiceLabels := []string{"service", "job"}
+	if v := os.Getenv("REMOTE_WRITE_SERVICE_LABELS"); v != "" {
+		remoteWriteServiceLabels = strings.Split(v, ",")
+	}
+
+	// Head sampling of stored contexts (SAMPLING_*), shared with the
+	// anomaly service
+	sampling, err := pipeline.SamplingFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ingestion quotas, enforced at every ingest path. Services without an
+	// entry in QUOTAS get QUOTA_DEFAULT_PER_SECOND and QUOTA_DEFAULT_PER_DAY;
+	// 0 (the default) is unlimited.
+	quotaDefault := pipeline.QuotaLimit{}
+	if v := os.Getenv("QUOTA_DEFAULT_PER_SECOND"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("invalid QUOTA_DEFAULT_PER_SECOND %q", v)
+		}
+		quotaDefault.PerSecond = r
+	}
+	if v := os.Getenv("QUOTA_DEFAULT_PER_DAY"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid QUOTA_DEFAULT_PER_DAY %q", v)
+		}
+		quotaDefault.PerDay = n
+	}
+
+	// "service=per_second[:per_day],...", e.g. "checkout=200:5000000,search=50"
+	quotas, err := pipeline.ParseQuotaLimits(os.Getenv("QUOTAS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QUOTAS: %w", err)
+	}
+
+	// Fraction of events over quota let through anyway; 0 drops them all
+	quotaExcessSampleRate := 0.0
+	if v := os.Getenv("QUOTA_EXCESS_SAMPLE_RATE"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("invalid QUOTA_EXCESS_SAMPLE_RATE %q", v)
+		}
+		quotaExcessSampleRate = r
+	}
+
+	// "key=tenant,...": callers of the HTTP and gRPC APIs present a key and
+	// only see their tenant's data. Unset, the deployment is single-tenant.
+	tenantKeys, err := tenant.ParseKeys(os.Getenv("TENANT_API_KEYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_API_KEYS: %w", err)
+	}
+
+	// Days of contexts, anomalies, rollups and metric points kept,
+	// enforced every RETENTION_INTERVAL, which also creates
+	// PARTITION_PREMAKE_DAYS of future daily partitions
+	retentionDays := map[string]int{
+		"contexts":           7,
+		"anomalies":          30,
+		"context_rollups_1m": 7,
+		"context_rollups_1h": 90,
+		"metric_points":      7,
+	}
+	for table, env := range map[string]string{
+		"contexts":           "RETENTION_CONTEXTS_DAYS",
+		"anomalies":          "RETENTION_ANOMALIES_DAYS",
+		"context_rollups_1m": "RETENTION_ROLLUPS_1M_DAYS",
+		"context_rollups_1h": "RETENTION_ROLLUPS_1H_DAYS",
+		"metric_points":      "RETENTION_METRIC_POINTS_DAYS",
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s %q", env, v)
+			}
+			retentionDays[table] = n
+		}
+	}
+
+	partitionPremakeDays := 3
+	if v := os.Getenv("PARTITION_PREMAKE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid PARTITION_PREMAKE_DAYS %q", v)
+		}
+		partitionPremakeDays = n
+	}
+
+	retentionInterval, err := pipeline.EnvDuration("RETENTION_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Contexts are rolled up every ROLLUP_INTERVAL; events arriving up to
+	// ROLLUP_LATENESS after their bucket closed are still counted
+	rollupInterval, err := pipeline.EnvDuration("ROLLUP_INTERVAL", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	rollupLateness := 5 * time.Minute
+	if v := os.Getenv("ROLLUP_LATENESS"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid ROLLUP_LATENESS %q", v)
+		}
+		rollupLateness = d
+	}
+
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
 		// default Postgres connection inside Docker
@@ -43,9 +314,43 @@ func LoadConfig() (*Config, error) {
 	}
 
 	return &Config{
-		RestPort:     restPort,
-		GrpcPort:     grpcPort,
-		KafkaBrokers: kafkaBrokers,
-		DatabaseURL:  databaseURL,
+		RestPort:                 restPort,
+		GrpcPort:                 grpcPort,
+		KafkaBrokers:             kafkaBrokers,
+		KafkaTopic:               kafkaTopic,
+		KafkaGroupID:             kafkaGroupID,
+		KafkaInitialOffset:       kafkaInitialOffset,
+		KafkaRebalanceStrategy:   kafkaRebalanceStrategy,
+		DLQTopic:                 dlqTopic,
+		DatabaseURL:              databaseURL,
+		Validation:               validation,
+		BatchSize:                batchSize,
+		BatchFlushInterval:       batchFlushInterval,
+		DedupWindow:              dedupWindow,
+		DedupMaxEntries:          dedupMaxEntries,
+		Workers:                  workers,
+		WorkerQueueSize:          workerQueueSize,
+		LagWarningThreshold:      lagWarningThreshold,
+		LagCheckInterval:         lagCheckInterval,
+		IngestMaxBatch:           ingestMaxBatch,
+		IngestMaxBodyBytes:       ingestMaxBodyBytes,
+		SyslogUDPAddr:            os.Getenv("SYSLOG_UDP_ADDR"),
+		SyslogTCPAddr:            os.Getenv("SYSLOG_TCP_ADDR"),
+		LogTailFiles:             logTailFiles,
+		LogTailPollInterval:      logTailPollInterval,
+		LogRegex:                 os.Getenv("LOG_REGEX"),
+		LogJSONFields:            os.Getenv("LOG_JSON_FIELDS"),
+		RemoteWriteMatchers:      os.Getenv("REMOTE_WRITE_MATCHERS"),
+		RemoteWriteServiceLabels: remoteWriteServiceLabels,
+		Sampling:                 sampling,
+		Quotas:                   quotas,
+		QuotaDefault:             quotaDefault,
+		QuotaExcessSampleRate:    quotaExcessSampleRate,
+		TenantKeys:               tenantKeys,
+		RetentionDays:            retentionDays,
+		PartitionPremakeDays:     partitionPremakeDays,
+		RetentionInterval:        retentionInterval,
+		RollupInterval:           rollupInterval,
+		RollupLateness:           rollupLateness,
 	}, nil
 }
@@ -1,101 +1,361 @@
 package consumer
 
 import (
-	"encoding/json"
-	"os"
-	"os/signal"
-	"strings"
-	"syscall"
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
-	"github.com/Shopify/sarama"
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
 )
 
-// Event represents a Kafka event message
-type Event struct {
-	TraceID   string `json:"trace_id"`
-	Service   string `json:"service"`
-	Level     string `json:"level"`      // maps to Status in DB
-	Message   string `json:"message"`    // optional
-	Timestamp string `json:"timestamp"`  // ISO8601 string
-	LatencyMs int    `json:"latency_ms"` // optional
-	QueueLen  int    `json:"queue_length"`
+// Config holds the consumer group settings
+type Config struct {
+	Topic             string
+	GroupID           string
+	InitialOffset     string // "oldest" or "newest", used when the group has no committed offset
+	RebalanceStrategy string // "range" or "roundrobin"
+	DLQTopic          string // rejected messages are forwarded here
+	Rules             schema.Rules
+	BatchSize         int           // flush after this many events...
+	FlushInterval     time.Duration // ...or once the oldest has waited this long
+	DedupWindow       time.Duration // how long event IDs are remembered
+	DedupMaxEntries   int
+	Workers           int               // events are processed concurrently across services
+	WorkerQueueSize   int               // events buffered per worker before reads block
+	Sampler           *pipeline.Sampler // decides which events are stored; nil keeps all
 }
 
-// Start consumes Kafka messages and saves them to DB
-func Start(brokers string, topic string, database *db.DB) error {
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-	config.Version = sarama.V2_1_0_0
+// finalFlushTimeout bounds the retries of the batches flushed on shutdown
+const finalFlushTimeout = 10 * time.Second
 
-	consumer, err := sarama.NewConsumer(strings.Split(brokers, ","), config)
+// Start joins the consumer group on the bus and saves its messages to the
+// store in batches, spread over a pool of workers, until ctx ends. Messages
+// are acked only after the batch holding them has been committed, so a
+// restart resumes from the last persisted message instead of the topic
+// head. On shutdown the pending batches are flushed and acked before
+// Start returns.
+func Start(ctx context.Context, cfg Config, b bus.Bus, store db.Store, status *pipeline.IngestStatus) error {
+	sub, err := b.Subscribe(cfg.Topic, cfg.GroupID,
+		bus.StartAt(cfg.InitialOffset), bus.WithBalance(cfg.RebalanceStrategy))
 	if err != nil {
 		return err
 	}
-	defer consumer.Close()
+	defer sub.Close()
 
-	partitionConsumer, err := consumer.ConsumePartition(topic, 0, sarama.OffsetNewest)
-	if err != nil {
-		return err
+	dlq := producer.New(b, cfg.DLQTopic)
+	defer dlq.Close()
+
+	logrus.Infof("✅ Listening for messages on topic: %s (group: %s)", cfg.Topic, cfg.GroupID)
+
+	h := &handler{
+		database:      store,
+		dlq:           dlq,
+		rules:         cfg.Rules,
+		groupID:       cfg.GroupID,
+		flushInterval: cfg.FlushInterval,
+		dedup:         pipeline.NewDedup(cfg.DedupWindow, cfg.DedupMaxEntries),
+		sampler:       cfg.Sampler,
+		status:        status,
+		ctx:           ctx,
+		topic:         cfg.Topic,
+		sub:           sub,
+		offsets:       make(map[int]*pipeline.OffsetTracker),
+		acked:         make(map[int]int64),
+	}
+	if h.flushInterval <= 0 {
+		h.flushInterval = time.Second
 	}
-	defer partitionConsumer.Close()
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	h.batches = make([]*db.BatchWriter, cfg.Workers)
+	for i := range h.batches {
+		h.batches[i] = store.NewBatchWriter(cfg.BatchSize, h.flushInterval)
+	}
+	h.pool = pipeline.NewPool(pipeline.PoolConfig{
+		Workers:   cfg.Workers,
+		QueueSize: cfg.WorkerQueueSize,
+		OnTick:    h.flushDue,
+		TickEvery: h.flushInterval / 2,
+		OnClose:   h.flushFinal,
+	})
 
-	// Graceful shutdown channel
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	err = h.run()
+	logrus.Info("🛑 Consumer shutting down...")
+
+	// Finish queued jobs, flush the rest, and ack whatever landed. A
+	// batch still failing after finalFlushTimeout stays unacked and is
+	// redelivered.
+	h.pool.Close()
+	h.ack()
+	return err
+}
 
-	logrus.Infof("✅ Listening for Kafka messages on topic: %s", topic)
+// handler owns the consumer's state
+type handler struct {
+	database db.Store
+	dlq      *producer.Publisher
+	rules    schema.Rules
+	groupID  string
 
+	flushInterval time.Duration
+	dedup         *pipeline.Dedup
+	sampler       *pipeline.Sampler
+	status        *pipeline.IngestStatus
+
+	// ctx ends when the consumer shuts down
+	ctx     context.Context
+	pool    *pipeline.Pool
+	batches []*db.BatchWriter // one per pool worker, only touched by it
+
+	topic   string
+	sub     bus.Subscription
+	offsets map[int]*pipeline.OffsetTracker // per partition, only touched by run
+	acked   map[int]int64                   // highest acked offset per partition
+}
+
+// run decodes messages on the fetch loop and hands them to the worker
+// pool keyed by tenant and service, so one service's events are stored in
+// order while different services are written concurrently. Each worker
+// batches its own writes; messages are acked once every message below
+// them has been stored.
+func (h *handler) run() error {
+	lastAck := time.Now()
 	for {
-		select {
-		case msg := <-partitionConsumer.Messages():
-			var e Event
-			if err := json.Unmarshal(msg.Value, &e); err != nil {
-				logrus.Errorf("Failed to unmarshal Kafka message: %v", err)
+		if time.Since(lastAck) >= h.flushInterval/2 {
+			h.ack()
+			lastAck = time.Now()
+		}
+
+		fetchCtx, cancel := context.WithTimeout(h.ctx, h.flushInterval/2)
+		msg, err := h.sub.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			switch {
+			case h.ctx.Err() != nil || errors.Is(err, bus.ErrClosed):
+				return nil
+			case errors.Is(err, context.DeadlineExceeded):
 				continue
 			}
+			logrus.Errorf("Consumer fetch failed: %v", err)
+			select {
+			case <-time.After(2 * time.Second):
+			case <-h.ctx.Done():
+			}
+			continue
+		}
 
-			// Parse timestamp
-			t, err := time.Parse(time.RFC3339, e.Timestamp)
-			var ts int64
-			if err != nil {
-				logrus.Warnf("Failed to parse timestamp, using current time: %v", err)
-				ts = time.Now().UnixMilli()
-			} else {
-				ts = t.UnixMilli()
+		offsets, ok := h.offsets[msg.Partition]
+		if !ok || !offsets.Track(msg.Offset) {
+			// New partition, or one rewound by a rebalance: start afresh
+			offsets = pipeline.NewOffsetTracker()
+			offsets.Track(msg.Offset)
+			h.offsets[msg.Partition] = offsets
+			if !ok {
+				h.acked[msg.Partition] = -1
 			}
+			logrus.WithFields(logrus.Fields{
+				"topic":     msg.Topic,
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+			}).Info("🔀 Consuming partition")
+		}
+		h.status.Observe(msg.Topic, int32(msg.Partition), msg.Offset, msg.HighWaterMark)
+
+		var eventTime int64
+		done := func() {
+			offsets.Done(msg.Offset)
+			h.status.Processed(msg.Topic, int32(msg.Partition), msg.Offset, eventTime)
+		}
 
-			// Map Kafka event to DB Event
-			dbEvent := db.Event{
-				Service:     e.Service,
-				Timestamp:   ts,
-				Status:      e.Level,
-				LatencyMs:   e.LatencyMs,
-				QueueLength: e.QueueLen,
+		e, err := h.rules.DecodeAndValidateAt(msg.Header(schema.HeaderContentType), msg.Value, msg.Time)
+		if err != nil {
+			if !retry(h.ctx, "dead-letter publish", func() error { return h.deadLetter(msg, err) }) {
+				return nil
 			}
+			done()
+			continue
+		}
+
+		eventTime = e.Timestamp
+		e.SetTenant(msg.Header(schema.HeaderTenant))
+
+		// Producer retries and redeliveries carry the same ID. An ID is
+		// only recorded once its event is stored, so a redelivery of one
+		// whose batch failed is stored after all; a duplicate that slips
+		// through meanwhile is dropped by the database.
+		e.EnsureEventID(msg.Header(schema.HeaderEventID), msg.Topic, msg.Partition, msg.Offset)
+		if h.dedup.Contains(e.EventID) {
+			logrus.WithField("event_id", e.EventID).Debug("Skipping duplicate event")
+			done()
+			continue
+		}
 
-			// Save to DB
-			if err := database.SaveContext(dbEvent); err != nil {
-				logrus.Errorf("Failed to save event to DB: %v", err)
+		// Routine traffic of chatty services is only partly stored
+		sampleRate := 1.0
+		if h.sampler != nil {
+			var keep bool
+			keep, sampleRate = h.sampler.Sample(e.Service, e.TraceID, e.EventID, e.Status != "success", e.LatencyMs)
+			if !keep {
+				h.dedup.Seen(e.EventID)
+				done()
 				continue
 			}
+		}
 
-			logrus.WithFields(logrus.Fields{
-				"trace_id": e.TraceID,
-				"service":  e.Service,
-				"status":   e.Level,
-				"latency":  e.LatencyMs,
-				"queue":    e.QueueLen,
-			}).Info("📥 Event saved to DB")
-
-		case err := <-partitionConsumer.Errors():
-			logrus.Errorf("Kafka consumer error: %v", err)
-
-		case <-signals:
-			logrus.Info("🛑 Kafka consumer shutting down...")
+		logrus.WithFields(logrus.Fields{
+			"event_id":  e.EventID,
+			"trace_id":  e.TraceID,
+			"service":   e.Service,
+			"tenant_id": e.TenantID,
+			"status":    e.Status,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+		}).Debug("📥 Event queued for DB")
+
+		// Blocks while the service's worker is backed up, which in turn
+		// stops us fetching
+		event := *e
+		stored := func() {
+			h.dedup.Seen(event.EventID)
+			done()
+		}
+		if err := h.pool.Submit(h.ctx, event.TenantID+"/"+event.Service, func(worker int) {
+			h.store(worker, event, sampleRate, stored)
+		}); err != nil {
 			return nil
 		}
 	}
 }
+
+// ack acknowledges every partition up to its first unfinished message
+func (h *handler) ack() {
+	var msgs []bus.Message
+	for partition, offsets := range h.offsets {
+		if offset, ok := offsets.Committable(); ok && offset > h.acked[partition] {
+			msgs = append(msgs, bus.Message{Topic: h.topic, Partition: partition, Offset: offset})
+		}
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	if err := h.sub.Ack(context.Background(), msgs...); err != nil {
+		// Acked again on the next round
+		logrus.Errorf("Failed to ack consumed messages: %v", err)
+		return
+	}
+	for _, m := range msgs {
+		h.acked[m.Partition] = m.Offset
+	}
+}
+
+// store runs on a pool worker and adds the event to that worker's batch
+func (h *handler) store(worker int, event db.Event, sampleRate float64, done func()) {
+	if h.batches[worker].Add(event, sampleRate, done, nil) {
+		h.flush(worker)
+	}
+}
+
+// flushDue runs on every worker tick
+func (h *handler) flushDue(worker int) {
+	if h.batches[worker].Due() {
+		h.flush(worker)
+	}
+}
+
+// flush keeps retrying a failed batch instead of moving on, so no offset
+// is ever marked past an event that isn't stored. Meanwhile the worker's
+// queue fills up and pushes back on the partitions feeding it.
+func (h *handler) flush(worker int) {
+	retry(h.ctx, "batch flush", h.batches[worker].Flush)
+}
+
+// flushFinal writes what the worker still holds once the pool closes.
+// h.ctx has ended by then, so retries run on a context of their own.
+func (h *handler) flushFinal(worker int) {
+	ctx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+	defer cancel()
+	retry(ctx, "final batch flush", h.batches[worker].Flush)
+}
+
+// retry runs fn with exponential backoff until it succeeds or ctx ends.
+// It reports whether fn eventually succeeded.
+func retry(ctx context.Context, what string, fn func() error) bool {
+	backoff := 500 * time.Millisecond
+	for {
+		err := fn()
+		if err == nil {
+			return true
+		}
+		logrus.WithField("retry_in", backoff).Warnf("Retrying %s: %v", what, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// deadLetter forwards a rejected message to the DLQ topic
+func (h *handler) deadLetter(msg bus.Message, reason error) error {
+	err := h.dlq.PublishDeadLetter(context.Background(), producer.DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Headers:   msg.Headers,
+		Consumer:  h.groupID,
+		Reason:    reason.Error(),
+	})
+	if err != nil {
+		return fmt.Errorf("dead-letter publish failed: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+		"reason":    reason.Error(),
+	}).Warn("☠️ Event rejected and sent to DLQ")
+	return nil
+}
+
+// WatchLag saves a warning anomaly about the pipeline itself whenever a
+// partition falls more than threshold messages behind, at most once per
+// partition per cooldown
+func WatchLag(ctx context.Context, status *pipeline.IngestStatus, store db.Store, threshold int64, interval, cooldown time.Duration) {
+	lastWarned := make(map[int32]time.Time)
+	status.WatchLag(ctx, threshold, interval, func(p pipeline.PartitionStatus) {
+		if time.Since(lastWarned[p.Partition]) < cooldown {
+			return
+		}
+		lastWarned[p.Partition] = time.Now()
+
+		logrus.WithFields(logrus.Fields{
+			"topic":     p.Topic,
+			"partition": p.Partition,
+			"lag":       p.Lag,
+			"threshold": threshold,
+		}).Warn("🐢 Consumer lag above threshold")
+
+		err := store.SaveAnomaly(db.Anomaly{
+			Type:        "consumer_lag",
+			Service:     "contextify",
+			QueueLength: int(p.Lag),
+			Timestamp:   time.Now().UnixMilli(),
+		})
+		if err != nil {
+			logrus.Errorf("Failed to save consumer lag anomaly: %v", err)
+		}
+	})
+}
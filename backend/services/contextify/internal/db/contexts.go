@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/migrate"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// AttrFilter matches rows whose attributes hold every key/value pair
+type AttrFilter = storage.AttrFilter
+
+// BatchWriter accumulates context events and writes them in one go
+type BatchWriter = storage.BatchWriter
+
+// BatchStats summarises batch flushes since startup
+type BatchStats = storage.BatchStats
+
+// NewBatchWriter returns a writer that wants flushing once it holds
+// maxSize events or its oldest event has waited maxWait
+func (db *DB) NewBatchWriter(maxSize int, maxWait time.Duration) *BatchWriter {
+	return storage.NewBatchWriter(db, db.batches, maxSize, maxWait)
+}
+
+// SaveContexts writes events inside a single transaction, skipping those
+// already stored
+func (db *DB) SaveContexts(events []SampledEvent) error {
+	return storage.SaveContexts(db.Conn, db.sqlite, events)
+}
+
+// BatchStats returns a snapshot of the batch flush metrics
+func (db *DB) BatchStats() BatchStats {
+	return db.batches.Snapshot()
+}
+
+// Migrator returns the schema migrator of a Postgres database
+func (db *DB) Migrator() (*migrate.Migrator, error) {
+	return storage.Migrator(db.Conn, db.sqlite)
+}
+
+// Migrate applies pending migrations to a Postgres database. It does
+// nothing on SQLite.
+func (db *DB) Migrate(ctx context.Context) ([]migrate.Migration, error) {
+	return storage.Migrate(ctx, db.Conn, db.sqlite)
+}
@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// Memory is a Store held in process memory, for tests and tools with no
+// database at hand. It keeps everything until the process ends or
+// retention deletes it. It has no partitions, and computes rollups from
+// the stored contexts when asked.
+type Memory struct {
+	mu        sync.RWMutex
+	contexts  []memContext
+	seen      map[contextKey]bool // like the unique (event_id, timestamp) index
+	anomalies []Anomaly
+	points    []MetricPoint
+	seenPoint map[pointKey]bool // like the unique (tenant_id, series, timestamp) index
+	batches   *storage.BatchMetrics
+}
+
+type contextKey struct {
+	eventID   string
+	timestamp int64
+}
+
+type pointKey struct {
+	tenant    string
+	series    string
+	timestamp int64
+}
+
+// memContext is a stored context, with its attributes in column form
+type memContext struct {
+	event      Event // Extra is nil
+	attributes string
+	sampleRate float64
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{seen: make(map[contextKey]bool), seenPoint: make(map[pointKey]bool), batches: &storage.BatchMetrics{}}
+}
+
+// SaveContext stores a context event. Events already stored under the
+// same ID and timestamp are skipped.
+func (m *Memory) SaveContext(event Event) error {
+	return m.SaveContexts([]SampledEvent{{Event: event, SampleRate: 1}})
+}
+
+// SaveContexts stores events kept with their sample rates, skipping those
+// already stored
+func (m *Memory) SaveContexts(events []SampledEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range events {
+		key := contextKey{e.EventID, e.Timestamp}
+		if m.seen[key] {
+			continue
+		}
+		m.seen[key] = true
+
+		c := memContext{event: e.Event, attributes: e.Attributes(), sampleRate: e.SampleRate}
+		c.event.TenantID = storage.TenantOf(e.TenantID)
+		c.event.Extra = nil
+		m.contexts = append(m.contexts, c)
+	}
+	return nil
+}
+
+// SaveAnomaly stores an anomaly
+func (m *Memory) SaveAnomaly(a Anomaly) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a.TenantID = storage.TenantOf(a.TenantID)
+	a.Attributes = json.RawMessage(storage.AttributesOrEmpty(a.Attributes))
+	m.anomalies = append(m.anomalies, a)
+	return nil
+}
+
+// NewBatchWriter returns a writer that wants flushing once it holds
+// maxSize events or its oldest event has waited maxWait
+func (m *Memory) NewBatchWriter(maxSize int, maxWait time.Duration) *BatchWriter {
+	return storage.NewBatchWriter(m, m.batches, maxSize, maxWait)
+}
+
+// BatchStats returns a snapshot of the batch flush metrics
+func (m *Memory) BatchStats() BatchStats {
+	return m.batches.Snapshot()
+}
+
+// GetRecentEvents returns a tenant's newest context events within span,
+// optionally only those matching attrs
+func (m *Memory) GetRecentEvents(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error) {
+	return m.queryContexts(tenant, attrs, limit, func(e *Event) bool {
+		return e.Service == service && span.Contains(e.Timestamp)
+	}), nil
+}
+
+// GetContextsByTraceID returns a tenant's newest contexts of a trace,
+// optionally only those matching attrs
+func (m *Memory) GetContextsByTraceID(tenant, traceID string, attrs AttrFilter, limit int) ([]Event, error) {
+	return m.queryContexts(tenant, attrs, limit, func(e *Event) bool {
+		return e.TraceID == traceID
+	}), nil
+}
+
+// queryContexts returns the tenant's newest contexts that match attrs
+// and keep
+func (m *Memory) queryContexts(tenant string, attrs AttrFilter, limit int, keep func(*Event) bool) []Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matched []memContext
+	for _, c := range m.contexts {
+		if c.event.TenantID == tenant && keep(&c.event) && attrs.Matches([]byte(c.attributes)) {
+			matched = append(matched, c)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].event.Timestamp > matched[j].event.Timestamp
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var events []Event
+	for _, c := range matched {
+		e := c.event
+		e.SetAttributes([]byte(c.attributes))
+		storage.SetSampleRate(&e, c.sampleRate)
+		e.SchemaVersion = schema.Version
+		events = append(events, e)
+	}
+	return events
+}
+
+// GetRecentAnomalies returns a tenant's newest anomalies within span,
+// optionally only those matching attrs
+func (m *Memory) GetRecentAnomalies(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Anomaly, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var anomalies []Anomaly
+	for _, a := range m.anomalies {
+		if a.TenantID == tenant && a.Service == service && span.Contains(a.Timestamp) && attrs.Matches(a.Attributes) {
+			anomalies = append(anomalies, a)
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Timestamp > anomalies[j].Timestamp
+	})
+	if len(anomalies) > limit {
+		anomalies = anomalies[:limit]
+	}
+	return anomalies, nil
+}
+
+// Rollups aggregates a tenant's service's contexts into the buckets of
+// res starting in [from, to), oldest first. Unlike a DB's, they are
+// always up to date.
+func (m *Memory) Rollups(tenant, service string, res Resolution, from, to time.Time) ([]Rollup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	width := res.Width.Milliseconds()
+	span := TimeRange{From: from, To: to}
+	buckets := make(map[int64]*rollupAcc)
+	for _, c := range m.contexts {
+		e := &c.event
+		bucket := e.Timestamp - e.Timestamp%width
+		if e.TenantID != tenant || e.Service != service || !span.Contains(bucket) {
+			continue
+		}
+		a, ok := buckets[bucket]
+		if !ok {
+			a = &rollupAcc{}
+			buckets[bucket] = a
+		}
+		a.add(sql.NullInt64{Int64: int64(e.LatencyMs), Valid: true}, e.Status, e.QueueLength, c.sampleRate)
+	}
+
+	rollups := make([]Rollup, 0, len(buckets))
+	for bucket, a := range buckets {
+		rollups = append(rollups, a.rollup(bucket))
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		return rollups[i].Bucket < rollups[j].Bucket
+	})
+	return rollups, nil
+}
+
+// Partitions fails with ErrNoPartitions; nothing in memory is
+// partitioned
+func (m *Memory) Partitions() ([]Partition, error) {
+	return nil, ErrNoPartitions
+}
+
+// SaveMetricPoints stores samples, skipping those already stored
+func (m *Memory) SaveMetricPoints(points []MetricPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range points {
+		p.TenantID = storage.TenantOf(p.TenantID)
+		key := pointKey{p.TenantID, p.Series, p.Timestamp}
+		if m.seenPoint[key] {
+			continue
+		}
+		m.seenPoint[key] = true
+		m.points = append(m.points, p)
+	}
+	return nil
+}
+
+// LatestRollup reports no buckets; Memory keeps none, computing them in
+// Rollups instead
+func (m *Memory) LatestRollup(res Resolution) (time.Time, bool, error) {
+	return time.Time{}, false, nil
+}
+
+// RollUp does nothing; Rollups is always up to date
+func (m *Memory) RollUp(res Resolution, from, to time.Time) (int64, error) {
+	return 0, nil
+}
+
+// EnsurePartitions does nothing; nothing in memory is partitioned
+func (m *Memory) EnsurePartitions(table string, through time.Time) (int, error) {
+	return 0, nil
+}
+
+// DropBefore deletes the contexts or anomalies older than cutoff. Having
+// no partitions, it never returns any.
+func (m *Memory) DropBefore(table string, cutoff time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms := cutoff.UnixMilli()
+	switch table {
+	case "contexts":
+		kept := m.contexts[:0]
+		for _, c := range m.contexts {
+			if c.event.Timestamp >= ms {
+				kept = append(kept, c)
+			} else {
+				delete(m.seen, contextKey{c.event.EventID, c.event.Timestamp})
+			}
+		}
+		m.contexts = kept
+	case "anomalies":
+		kept := m.anomalies[:0]
+		for _, a := range m.anomalies {
+			if a.Timestamp >= ms {
+				kept = append(kept, a)
+			}
+		}
+		m.anomalies = kept
+	}
+	return nil, nil
+}
+
+// DeleteRollupsBefore does nothing; Memory keeps no buckets
+func (m *Memory) DeleteRollupsBefore(res Resolution, cutoff time.Time) (int64, error) {
+	return 0, nil
+}
+
+// DeleteMetricPointsBefore removes the samples taken before cutoff
+func (m *Memory) DeleteMetricPointsBefore(cutoff time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms := cutoff.UnixMilli()
+	var deleted int64
+	kept := m.points[:0]
+	for _, p := range m.points {
+		if p.Timestamp >= ms {
+			kept = append(kept, p)
+			continue
+		}
+		delete(m.seenPoint, pointKey{p.TenantID, p.Series, p.Timestamp})
+		deleted++
+	}
+	m.points = kept
+	return deleted, nil
+}
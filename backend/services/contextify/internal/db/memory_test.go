@@ -0,0 +1,72 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+func TestMemoryBatchIdempotent(t *testing.T) {
+	m := NewMemory()
+	w := m.NewBatchWriter(10, time.Minute)
+
+	events := []Event{
+		{EventID: "a", Service: "checkout", Timestamp: 1000, TenantID: "acme"},
+		{EventID: "b", Service: "checkout", Timestamp: 2000, TenantID: "acme"},
+		{EventID: "c", Service: "checkout", Timestamp: 3000},
+	}
+	acks := 0
+	for _, e := range events {
+		w.Add(e, 0.5, func() { acks++ }, nil)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+
+	// A redelivered batch, with one event the first didn't hold
+	for _, e := range append(events, Event{EventID: "d", Service: "checkout", Timestamp: 4000, TenantID: "acme"}) {
+		w.Add(e, 0.5, func() { acks++ }, nil)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("second Flush() = %v", err)
+	}
+	if acks != 7 {
+		t.Errorf("acks = %d, want 7", acks)
+	}
+
+	got, err := m.GetRecentEvents("acme", "checkout", TimeRange{}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetRecentEvents: %v", err)
+	}
+	var ids []string
+	for _, e := range got {
+		ids = append(ids, e.EventID)
+	}
+	if len(ids) != 3 || ids[0] != "d" || ids[1] != "b" || ids[2] != "a" {
+		t.Errorf("acme's events = %v, want [d b a]", ids)
+	}
+
+	// An event without a tenant is stored under the default one
+	got, _ = m.GetRecentEvents(schema.DefaultTenant, "checkout", TimeRange{}, nil, 10)
+	if len(got) != 1 || got[0].EventID != "c" {
+		t.Errorf("default tenant's events = %v, want [c]", got)
+	}
+
+	stats := m.BatchStats()
+	if stats.Batches != 2 || stats.Events != 7 || stats.Failures != 0 {
+		t.Errorf("BatchStats() = %+v, want 2 batches of 7 events", stats)
+	}
+}
+
+func TestMemoryKeepsSameIDAtAnotherTime(t *testing.T) {
+	m := NewMemory()
+	m.SaveContext(Event{EventID: "a", Service: "checkout", Timestamp: 1000})
+	m.SaveContext(Event{EventID: "a", Service: "checkout", Timestamp: 1000})
+	m.SaveContext(Event{EventID: "a", Service: "checkout", Timestamp: 2000})
+
+	got, _ := m.GetRecentEvents(schema.DefaultTenant, "checkout", TimeRange{}, nil, 10)
+	if len(got) != 2 {
+		t.Errorf("stored %d events, want 2: the unique key is ID and timestamp", len(got))
+	}
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// MetricPoint is one sample of a Prometheus series, linked to a service
+type MetricPoint struct {
+	Service   string            `json:"service"`
+	TenantID  string            `json:"tenant_id"`
+	Name      string            `json:"name"`
+	Series    string            `json:"series"`
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+	Timestamp int64             `json:"timestamp"` // ms
+}
+
+// SaveMetricPoints writes samples in one transaction, through a staging
+// table like SaveContexts so resent samples are skipped
+func (db *DB) SaveMetricPoints(points []MetricPoint) error {
+	if db.sqlite {
+		return db.saveMetricPointsSQLite(points)
+	}
+
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`CREATE TEMP TABLE metric_points_staging (
+			service TEXT, name TEXT, series TEXT, labels JSONB,
+			value DOUBLE PRECISION, timestamp TIMESTAMP, tenant_id TEXT
+		 ) ON COMMIT DROP`,
+	); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("metric_points_staging",
+		"service", "name", "series", "labels", "value", "timestamp", "tenant_id"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		labels, err := json.Marshal(p.Labels)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+		if _, err := stmt.Exec(
+			p.Service, p.Name, p.Series, string(labels), p.Value, time.UnixMilli(p.Timestamp).UTC(), storage.TenantOf(p.TenantID),
+		); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO metric_points(service, name, series, labels, value, timestamp, tenant_id)
+		 SELECT service, name, series, labels, value, timestamp, tenant_id
+		 FROM metric_points_staging
+		 ON CONFLICT (tenant_id, series, timestamp) DO NOTHING`,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) saveMetricPointsSQLite(points []MetricPoint) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO metric_points(service, name, series, labels, value, timestamp, tenant_id)
+		 VALUES($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (tenant_id, series, timestamp) DO NOTHING`,
+	)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, p := range points {
+		labels, err := json.Marshal(p.Labels)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(p.Service, p.Name, p.Series, string(labels), p.Value, p.Timestamp, storage.TenantOf(p.TenantID)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteMetricPointsBefore removes the samples taken before cutoff
+func (db *DB) DeleteMetricPointsBefore(cutoff time.Time) (int64, error) {
+	result, err := db.Conn.Exec(
+		`DELETE FROM metric_points WHERE timestamp < `+db.fromMillis(1), cutoff.UnixMilli(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PartitionedTables are split into daily partitions on Postgres, see
+// migration 011
+var PartitionedTables = []string{"contexts", "anomalies"}
+
+// ErrNoPartitions is returned for partition lookups on SQLite and in
+// memory, neither of which partitions its tables
+var ErrNoPartitions = errors.New("partitions need Postgres")
+
+// Partition is one partition of a partitioned table and its size
+type Partition struct {
+	Table string `json:"table"`
+	Name  string `json:"name"`
+	Day   string `json:"day,omitempty"` // UTC date it holds; empty for the default partition
+	Rows  int64  `json:"rows_estimate"` // planner estimate, 0 until analyzed
+	Bytes int64  `json:"bytes"`         // including indexes and TOAST
+}
+
+// partitionDay is the date a daily partition named <table>_pYYYYMMDD holds
+func partitionDay(table, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, table+"_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("20060102", suffix)
+	return day, err == nil
+}
+
+// Partitions lists the partitions of the partitioned tables, oldest first
+func (db *DB) Partitions() ([]Partition, error) {
+	if db.sqlite {
+		return nil, ErrNoPartitions
+	}
+
+	rows, err := db.Conn.Query(
+		`SELECT parent.relname, child.relname, GREATEST(child.reltuples, 0)::bigint, pg_total_relation_size(child.oid)
+		 FROM pg_inherits i
+		 JOIN pg_class parent ON parent.oid = i.inhparent
+		 JOIN pg_class child ON child.oid = i.inhrelid
+		 WHERE parent.relname = ANY($1)
+		 ORDER BY parent.relname, child.relname`,
+		pq.Array(PartitionedTables),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []Partition
+	for rows.Next() {
+		var p Partition
+		if err := rows.Scan(&p.Table, &p.Name, &p.Rows, &p.Bytes); err != nil {
+			return nil, err
+		}
+		if day, ok := partitionDay(p.Table, p.Name); ok {
+			p.Day = day.Format("2006-01-02")
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, rows.Err()
+}
+
+// EnsurePartitions creates the table's missing daily partitions from today
+// (UTC) through the given day, and returns how many it created. Rows of those
+// days that landed in the default partition meanwhile are moved into
+// them. It does nothing on SQLite.
+func (db *DB) EnsurePartitions(table string, through time.Time) (int, error) {
+	if db.sqlite {
+		return 0, nil
+	}
+	var created int
+	err := db.Conn.QueryRow(
+		`SELECT create_daily_partitions($1, $2::date, $3::date)`,
+		table, time.Now().UTC().Format("2006-01-02"), through.UTC().Format("2006-01-02"),
+	).Scan(&created)
+	return created, err
+}
+
+// DropBefore removes the table's rows older than cutoff: daily partitions
+// ending by then are dropped whole, and stray rows in the default
+// partition deleted. It returns the partitions dropped. On SQLite the rows
+// are deleted instead.
+func (db *DB) DropBefore(table string, cutoff time.Time) ([]string, error) {
+	if db.sqlite {
+		_, err := db.Conn.Exec(`DELETE FROM `+pq.QuoteIdentifier(table)+` WHERE timestamp < $1`, cutoff.UnixMilli())
+		if err == nil && table == "anomalies" {
+			_, err = db.Conn.Exec(`DELETE FROM anomaly_keys WHERE created_at < $1`, cutoff.UnixMilli())
+		}
+		return nil, err
+	}
+
+	partitions, err := db.Partitions()
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, p := range partitions {
+		day, ok := partitionDay(table, p.Name)
+		if p.Table != table || !ok || day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+		if _, err := db.Conn.Exec(`DROP TABLE IF EXISTS ` + pq.QuoteIdentifier(p.Name)); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, p.Name)
+	}
+
+	if _, err := db.Conn.Exec(
+		`DELETE FROM `+pq.QuoteIdentifier(table+"_default")+` WHERE timestamp < `+db.fromMillis(1), cutoff.UnixMilli(),
+	); err != nil {
+		return dropped, err
+	}
+
+	// Dedup keys only need to outlive redeliveries of the anomalies they
+	// guard
+	if table == "anomalies" {
+		if _, err := db.Conn.Exec(
+			`DELETE FROM anomaly_keys WHERE created_at < `+db.fromMillis(1), cutoff.UnixMilli(),
+		); err != nil {
+			return dropped, err
+		}
+	}
+	return dropped, nil
+}
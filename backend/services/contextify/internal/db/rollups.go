@@ -0,0 +1,262 @@
+package db
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"time"
+)
+
+// Resolution is the bucket width of a rollup table
+type Resolution struct {
+	Name  string
+	Width time.Duration
+	Table string
+	trunc string // date_trunc field
+}
+
+var (
+	Minute = Resolution{Name: "1m", Width: time.Minute, Table: "context_rollups_1m", trunc: "minute"}
+	Hour   = Resolution{Name: "1h", Width: time.Hour, Table: "context_rollups_1h", trunc: "hour"}
+)
+
+// Resolutions are the rollup resolutions, finest first
+var Resolutions = []Resolution{Minute, Hour}
+
+// Rollup aggregates one service's contexts over one bucket. Counts and
+// the average latency estimate real events from sample rates; percentiles
+// are over the stored rows.
+type Rollup struct {
+	Bucket         int64   `json:"bucket"` // start, unix millis
+	Count          int64   `json:"count"`
+	ErrorCount     int64   `json:"error_count"`
+	LatencyMin     float64 `json:"latency_min"`
+	LatencyAvg     float64 `json:"latency_avg"`
+	LatencyMax     float64 `json:"latency_max"`
+	LatencyP50     float64 `json:"latency_p50"`
+	LatencyP95     float64 `json:"latency_p95"`
+	LatencyP99     float64 `json:"latency_p99"`
+	QueueLengthMax int     `json:"queue_length_max"`
+}
+
+const rollupColumns = `count, error_count, latency_min, latency_avg, latency_max, latency_p50, latency_p95, latency_p99, queue_length_max`
+
+// upsertRollup replaces whatever an earlier pass computed for the bucket
+const upsertRollup = ` ON CONFLICT (tenant_id, service, bucket) DO UPDATE SET
+	count = excluded.count, error_count = excluded.error_count,
+	latency_min = excluded.latency_min, latency_avg = excluded.latency_avg, latency_max = excluded.latency_max,
+	latency_p50 = excluded.latency_p50, latency_p95 = excluded.latency_p95, latency_p99 = excluded.latency_p99,
+	queue_length_max = excluded.queue_length_max`
+
+// RollUp recomputes every bucket of res holding contexts timestamped in
+// [from, to), from aligned down to a bucket start, and returns how many
+// buckets it wrote
+func (db *DB) RollUp(res Resolution, from, to time.Time) (int64, error) {
+	from = from.Truncate(res.Width)
+	if db.sqlite {
+		return db.rollUpSQLite(res, from, to)
+	}
+
+	result, err := db.Conn.Exec(
+		`INSERT INTO `+res.Table+`(tenant_id, service, bucket, `+rollupColumns+`)
+		 SELECT tenant_id, service, date_trunc('`+res.trunc+`', timestamp) AS bucket,
+			ROUND(SUM(1 / sample_rate))::bigint,
+			ROUND(SUM(CASE WHEN status <> 'success' THEN 1 / sample_rate ELSE 0 END))::bigint,
+			MIN(latency_ms),
+			SUM(latency_ms / sample_rate) / NULLIF(SUM(1 / sample_rate) FILTER (WHERE latency_ms IS NOT NULL), 0),
+			MAX(latency_ms),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY latency_ms),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms),
+			percentile_cont(0.99) WITHIN GROUP (ORDER BY latency_ms),
+			MAX(queue_length)
+		 FROM contexts
+		 WHERE timestamp >= `+db.fromMillis(1)+` AND timestamp < `+db.fromMillis(2)+`
+		 GROUP BY tenant_id, service, bucket`+upsertRollup,
+		from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rollUpSQLite aggregates in Go, SQLite having no percentiles
+func (db *DB) rollUpSQLite(res Resolution, from, to time.Time) (int64, error) {
+	rows, err := db.Conn.Query(
+		`SELECT tenant_id, service, timestamp, latency_ms, status, COALESCE(queue_length, 0), sample_rate
+		 FROM contexts
+		 WHERE timestamp >= $1 AND timestamp < $2`,
+		from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type key struct {
+		tenant, service string
+		bucket          int64
+	}
+	buckets := make(map[key]*rollupAcc)
+	width := res.Width.Milliseconds()
+	for rows.Next() {
+		var k key
+		var ts int64
+		var latency sql.NullInt64
+		var status sql.NullString
+		var queue int
+		var rate float64
+		if err := rows.Scan(&k.tenant, &k.service, &ts, &latency, &status, &queue, &rate); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		k.bucket = ts - ts%width
+		a, ok := buckets[k]
+		if !ok {
+			a = &rollupAcc{}
+			buckets[k] = a
+		}
+		a.add(latency, status.String, queue, rate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(
+		`INSERT INTO ` + res.Table + `(tenant_id, service, bucket, ` + rollupColumns + `)
+		 VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)` + upsertRollup,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for k, a := range buckets {
+		r := a.rollup(k.bucket)
+		var min, avg, max, p50, p95, p99 interface{}
+		if len(a.latencies) > 0 {
+			min, avg, max = r.LatencyMin, r.LatencyAvg, r.LatencyMax
+			p50, p95, p99 = r.LatencyP50, r.LatencyP95, r.LatencyP99
+		}
+		if _, err := stmt.Exec(k.tenant, k.service, k.bucket, r.Count, r.ErrorCount,
+			min, avg, max, p50, p95, p99, r.QueueLengthMax); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(buckets)), tx.Commit()
+}
+
+// rollupAcc aggregates the contexts of one bucket in Go, as RollUp's SQL
+// does
+type rollupAcc struct {
+	weight, errors, latencyWeight, latencySum float64
+	latencies                                 []float64
+	queueMax                                  int
+}
+
+// add counts a context stored with the given sample rate
+func (a *rollupAcc) add(latency sql.NullInt64, status string, queue int, rate float64) {
+	a.weight += 1 / rate
+	if status != "success" {
+		a.errors += 1 / rate
+	}
+	if latency.Valid {
+		a.latencies = append(a.latencies, float64(latency.Int64))
+		a.latencySum += float64(latency.Int64) / rate
+		a.latencyWeight += 1 / rate
+	}
+	if queue > a.queueMax {
+		a.queueMax = queue
+	}
+}
+
+// rollup returns the bucket starting at bucket; its latencies are 0 when
+// no context had one
+func (a *rollupAcc) rollup(bucket int64) Rollup {
+	r := Rollup{
+		Bucket:         bucket,
+		Count:          int64(math.Round(a.weight)),
+		ErrorCount:     int64(math.Round(a.errors)),
+		QueueLengthMax: a.queueMax,
+	}
+	if len(a.latencies) > 0 {
+		sort.Float64s(a.latencies)
+		r.LatencyMin, r.LatencyMax = a.latencies[0], a.latencies[len(a.latencies)-1]
+		r.LatencyAvg = a.latencySum / a.latencyWeight
+		r.LatencyP50 = percentile(a.latencies, 0.5)
+		r.LatencyP95 = percentile(a.latencies, 0.95)
+		r.LatencyP99 = percentile(a.latencies, 0.99)
+	}
+	return r
+}
+
+// percentile interpolates between the closest ranks of sorted, like
+// Postgres' percentile_cont
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// LatestRollup returns the start of the newest bucket of res, false when
+// none was rolled up yet
+func (db *DB) LatestRollup(res Resolution) (time.Time, bool, error) {
+	var latest sql.NullFloat64
+	err := db.Conn.QueryRow(`SELECT MAX(` + db.toMillis("bucket") + `) FROM ` + res.Table).Scan(&latest)
+	if err != nil || !latest.Valid {
+		return time.Time{}, false, err
+	}
+	return time.UnixMilli(int64(latest.Float64)), true, nil
+}
+
+// Rollups returns a tenant's service's buckets of res starting in
+// [from, to), oldest first
+func (db *DB) Rollups(tenant, service string, res Resolution, from, to time.Time) ([]Rollup, error) {
+	rows, err := db.Conn.Query(
+		`SELECT `+db.toMillis("bucket")+` AS b, count, error_count,
+			COALESCE(latency_min, 0), COALESCE(latency_avg, 0), COALESCE(latency_max, 0),
+			COALESCE(latency_p50, 0), COALESCE(latency_p95, 0), COALESCE(latency_p99, 0),
+			COALESCE(queue_length_max, 0)
+		 FROM `+res.Table+`
+		 WHERE tenant_id = $1 AND service = $2 AND bucket >= `+db.fromMillis(3)+` AND bucket < `+db.fromMillis(4)+`
+		 ORDER BY bucket`,
+		tenant, service, from.UnixMilli(), to.UnixMilli(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []Rollup
+	for rows.Next() {
+		var r Rollup
+		var bucket float64
+		if err := rows.Scan(&bucket, &r.Count, &r.ErrorCount, &r.LatencyMin, &r.LatencyAvg, &r.LatencyMax,
+			&r.LatencyP50, &r.LatencyP95, &r.LatencyP99, &r.QueueLengthMax); err != nil {
+			return nil, err
+		}
+		r.Bucket = int64(bucket)
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// DeleteRollupsBefore removes the buckets of res starting before cutoff
+func (db *DB) DeleteRollupsBefore(res Resolution, cutoff time.Time) (int64, error) {
+	result, err := db.Conn.Exec(
+		`DELETE FROM `+res.Table+` WHERE bucket < `+db.fromMillis(1), cutoff.UnixMilli(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
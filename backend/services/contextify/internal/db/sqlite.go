@@ -0,0 +1,152 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+	_ "modernc.org/sqlite"
+)
+
+// SQLitePrefix marks a DSN naming a local SQLite file instead of a
+// Postgres server
+const SQLitePrefix = storage.SQLitePrefix
+
+// sqliteSchema mirrors pkg/migrate/migrations, without the partitioning:
+// TestSQLiteSchemaMatchesMigrations checks both have the same columns.
+// Timestamps are stored as Unix milliseconds and JSONB as text.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS contexts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_id TEXT NOT NULL,
+    trace_id TEXT NOT NULL DEFAULT '',
+    service TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    timestamp INTEGER NOT NULL,
+    latency_ms INT,
+    status TEXT,
+    queue_length INT,
+    message TEXT NOT NULL DEFAULT '',
+    attributes TEXT NOT NULL DEFAULT '{}',
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contexts_event_id_time ON contexts(event_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_contexts_tenant_trace_time ON contexts(tenant_id, trace_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_contexts_tenant_service_time ON contexts(tenant_id, service, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS anomalies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    service TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    trace_id TEXT,
+    latency_ms INT,
+    error_rate DOUBLE PRECISION,
+    queue_length INT,
+    timestamp INTEGER NOT NULL,
+    dedup_key TEXT,
+    attributes TEXT NOT NULL DEFAULT '{}'
+);
+CREATE INDEX IF NOT EXISTS idx_anomalies_dedup_key ON anomalies(dedup_key);
+CREATE INDEX IF NOT EXISTS idx_anomalies_tenant_service_time ON anomalies(tenant_id, service, timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS anomaly_keys (
+    dedup_key TEXT PRIMARY KEY,
+    published BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+
+CREATE TABLE IF NOT EXISTS metric_points (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    series TEXT NOT NULL,
+    labels TEXT NOT NULL DEFAULT '{}',
+    value DOUBLE PRECISION NOT NULL,
+    timestamp INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_points_tenant_series_time ON metric_points(tenant_id, series, timestamp);
+CREATE INDEX IF NOT EXISTS idx_metric_points_tenant_service_name_time ON metric_points(tenant_id, service, name, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_metric_points_time ON metric_points(timestamp);
+
+CREATE TABLE IF NOT EXISTS anomalies_replay (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    replay_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    service TEXT NOT NULL,
+    tenant_id TEXT NOT NULL DEFAULT 'default',
+    trace_id TEXT,
+    latency_ms INT,
+    error_rate DOUBLE PRECISION,
+    queue_length INT,
+    timestamp INTEGER NOT NULL,
+    dedup_key TEXT NOT NULL,
+    attributes TEXT NOT NULL DEFAULT '{}',
+    created_at INTEGER NOT NULL DEFAULT (CAST(strftime('%s', 'now') AS INTEGER) * 1000)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_anomalies_replay_dedup ON anomalies_replay(replay_id, dedup_key);
+
+CREATE TABLE IF NOT EXISTS context_rollups_1m (
+    tenant_id TEXT NOT NULL,
+    service TEXT NOT NULL,
+    bucket INTEGER NOT NULL,
+    count INTEGER NOT NULL,
+    error_count INTEGER NOT NULL,
+    latency_min DOUBLE PRECISION,
+    latency_avg DOUBLE PRECISION,
+    latency_max DOUBLE PRECISION,
+    latency_p50 DOUBLE PRECISION,
+    latency_p95 DOUBLE PRECISION,
+    latency_p99 DOUBLE PRECISION,
+    queue_length_max INT,
+    PRIMARY KEY (tenant_id, service, bucket)
+);
+CREATE INDEX IF NOT EXISTS idx_context_rollups_1m_bucket ON context_rollups_1m(bucket);
+
+CREATE TABLE IF NOT EXISTS context_rollups_1h (
+    tenant_id TEXT NOT NULL,
+    service TEXT NOT NULL,
+    bucket INTEGER NOT NULL,
+    count INTEGER NOT NULL,
+    error_count INTEGER NOT NULL,
+    latency_min DOUBLE PRECISION,
+    latency_avg DOUBLE PRECISION,
+    latency_max DOUBLE PRECISION,
+    latency_p50 DOUBLE PRECISION,
+    latency_p95 DOUBLE PRECISION,
+    latency_p99 DOUBLE PRECISION,
+    queue_length_max INT,
+    PRIMARY KEY (tenant_id, service, bucket)
+);
+CREATE INDEX IF NOT EXISTS idx_context_rollups_1h_bucket ON context_rollups_1h(bucket);
+`
+
+// openSQLite opens (creating if needed) the SQLite file at path and makes
+// sure the schema exists. Several processes or DB handles may share the
+// file: writes wait for each other instead of failing.
+func openSQLite(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer at a time anyway
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(sqliteSchema); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// fromMillis is the SQL for storing the Unix-millisecond parameter n in a
+// timestamp column
+func (db *DB) fromMillis(n int) string {
+	return storage.FromMillis(n, db.sqlite)
+}
+
+// toMillis is the SQL reading a timestamp column as Unix milliseconds
+func (db *DB) toMillis(column string) string {
+	return storage.ToMillis(column, db.sqlite)
+}
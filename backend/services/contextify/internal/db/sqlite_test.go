@@ -0,0 +1,187 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/migrate"
+)
+
+var (
+	sqlComment  = regexp.MustCompile(`--[^\n]*`)
+	sqlFunction = regexp.MustCompile(`(?s)\$\$.*?\$\$`)
+	createTable = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*(.*)$`)
+	likeTable   = regexp.MustCompile(`(?i)^\(\s*LIKE (\w+)`)
+	alterTable  = regexp.MustCompile(`(?is)^ALTER TABLE (?:IF EXISTS )?(\w+)\s+(.*)$`)
+	addColumn   = regexp.MustCompile(`(?i)ADD COLUMN (?:IF NOT EXISTS )?(\w+)`)
+	dropColumn  = regexp.MustCompile(`(?i)DROP COLUMN (?:IF EXISTS )?(\w+)`)
+	renameTable = regexp.MustCompile(`(?i)^RENAME TO (\w+)`)
+	dropTable   = regexp.MustCompile(`(?i)^DROP TABLE (?:IF EXISTS )?(\w+)`)
+	notAColumn  = map[string]bool{"PRIMARY": true, "UNIQUE": true, "CONSTRAINT": true, "FOREIGN": true, "CHECK": true}
+)
+
+// migratedColumns replays the CREATE, ALTER and DROP TABLE statements of
+// the migrations, returning the sorted columns of each table they leave.
+// Partitions are left out.
+func migratedColumns(t *testing.T) map[string][]string {
+	migrations, err := migrate.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := make(map[string][]string)
+	for _, m := range migrations {
+		script := sqlFunction.ReplaceAllString(sqlComment.ReplaceAllString(m.Up(), ""), "")
+		for _, stmt := range strings.Split(script, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if c := createTable.FindStringSubmatch(stmt); c != nil {
+				name, rest := c[1], c[2]
+				switch {
+				case strings.Contains(strings.ToUpper(rest), "PARTITION OF"):
+				case likeTable.MatchString(rest):
+					tables[name] = append([]string(nil), tables[likeTable.FindStringSubmatch(rest)[1]]...)
+				default:
+					tables[name] = columnDefs(rest)
+				}
+			} else if a := alterTable.FindStringSubmatch(stmt); a != nil {
+				name, action := a[1], a[2]
+				if r := renameTable.FindStringSubmatch(action); r != nil {
+					tables[r[1]] = tables[name]
+					delete(tables, name)
+					continue
+				}
+				for _, col := range addColumn.FindAllStringSubmatch(action, -1) {
+					if !contains(tables[name], col[1]) {
+						tables[name] = append(tables[name], col[1])
+					}
+				}
+				for _, col := range dropColumn.FindAllStringSubmatch(action, -1) {
+					tables[name] = remove(tables[name], col[1])
+				}
+			} else if d := dropTable.FindStringSubmatch(stmt); d != nil {
+				delete(tables, d[1])
+			}
+		}
+	}
+	for _, cols := range tables {
+		sort.Strings(cols)
+	}
+	return tables
+}
+
+// columnDefs is the column names in the parenthesized definitions of a
+// CREATE TABLE
+func columnDefs(defs string) []string {
+	defs = strings.TrimSpace(defs)
+	defs = strings.TrimSuffix(strings.TrimPrefix(defs, "("), ")")
+	var cols []string
+	depth, start := 0, 0
+	for i := 0; i <= len(defs); i++ {
+		if i < len(defs) {
+			switch defs[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if fields := strings.Fields(defs[start:i]); len(fields) > 0 && !notAColumn[strings.ToUpper(fields[0])] {
+			cols = append(cols, fields[0])
+		}
+		start = i + 1
+	}
+	return cols
+}
+
+func contains(cols []string, col string) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(cols []string, col string) []string {
+	kept := cols[:0]
+	for _, c := range cols {
+		if c != col {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func TestSQLiteSchemaMatchesMigrations(t *testing.T) {
+	conn, err := openSQLite(filepath.Join(t.TempDir(), "contextify.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query(
+		`SELECT m.name, p.name FROM sqlite_master m, pragma_table_info(m.name) p
+		 WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	sqlite := make(map[string][]string)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			t.Fatal(err)
+		}
+		sqlite[table] = append(sqlite[table], column)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for _, cols := range sqlite {
+		sort.Strings(cols)
+	}
+
+	if want := migratedColumns(t); !reflect.DeepEqual(sqlite, want) {
+		t.Errorf("SQLite schema has columns\n%v\nmigrations leave\n%v", sqlite, want)
+	}
+}
+
+func TestDeleteMetricPointsBefore(t *testing.T) {
+	database, err := NewDB(SQLitePrefix + filepath.Join(t.TempDir(), "contextify.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Conn.Close()
+
+	cutoff := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	err = database.SaveMetricPoints([]MetricPoint{
+		{Service: "checkout", Name: "queue_depth", Series: "queue_depth", Value: 1, Timestamp: cutoff.Add(-time.Minute).UnixMilli()},
+		{Service: "checkout", Name: "queue_depth", Series: "queue_depth", Value: 2, Timestamp: cutoff.UnixMilli()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := database.DeleteMetricPointsBefore(cutoff)
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteMetricPointsBefore = %d, %v; want the older sample deleted", deleted, err)
+	}
+	var left int
+	if err := database.Conn.QueryRow(`SELECT COUNT(*) FROM metric_points`).Scan(&left); err != nil || left != 1 {
+		t.Errorf("%d samples left (%v), want 1", left, err)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/storage"
+)
+
+// Store is the storage the handlers and the consumer work against: saving
+// contexts and anomalies and querying them back. *DB keeps them in
+// Postgres, or SQLite in dev mode; Memory keeps them in process memory.
+// Every query is scoped to one tenant, and the Get queries return the
+// newest rows first.
+type Store interface {
+	SaveContext(event Event) error
+	SaveContexts(events []SampledEvent) error
+	SaveAnomaly(a Anomaly) error
+
+	// NewBatchWriter returns a writer flushing into the store, whose
+	// flushes are reported by BatchStats
+	NewBatchWriter(maxSize int, maxWait time.Duration) *BatchWriter
+	BatchStats() BatchStats
+
+	GetRecentEvents(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Event, error)
+	GetContextsByTraceID(tenant, traceID string, attrs AttrFilter, limit int) ([]Event, error)
+	GetRecentAnomalies(tenant, service string, span TimeRange, attrs AttrFilter, limit int) ([]Anomaly, error)
+
+	// Rollups returns a service's buckets of res starting in [from, to),
+	// oldest first
+	Rollups(tenant, service string, res Resolution, from, to time.Time) ([]Rollup, error)
+
+	// Partitions lists the daily partitions of PartitionedTables, or
+	// fails with ErrNoPartitions when the store has none
+	Partitions() ([]Partition, error)
+}
+
+// MetricStore keeps the Prometheus samples received over remote write
+type MetricStore interface {
+	SaveMetricPoints(points []MetricPoint) error
+}
+
+// RollupStore keeps the rollup tables up to date
+type RollupStore interface {
+	// LatestRollup returns the start of the newest bucket of res, if any
+	LatestRollup(res Resolution) (time.Time, bool, error)
+	// RollUp recomputes the buckets of res starting in [from, to),
+	// returning how many it wrote
+	RollUp(res Resolution, from, to time.Time) (int64, error)
+}
+
+// RetentionStore creates the partitions rows are about to land in and
+// removes rows past their retention
+type RetentionStore interface {
+	EnsurePartitions(table string, through time.Time) (int, error)
+	DropBefore(table string, cutoff time.Time) ([]string, error)
+	DeleteRollupsBefore(res Resolution, cutoff time.Time) (int64, error)
+	DeleteMetricPointsBefore(cutoff time.Time) (int64, error)
+}
+
+// Both implementations satisfy every store interface
+var (
+	_ Store          = (*DB)(nil)
+	_ Store          = (*Memory)(nil)
+	_ MetricStore    = (*DB)(nil)
+	_ MetricStore    = (*Memory)(nil)
+	_ RollupStore    = (*DB)(nil)
+	_ RollupStore    = (*Memory)(nil)
+	_ RetentionStore = (*DB)(nil)
+	_ RetentionStore = (*Memory)(nil)
+)
+
+// TimeRange bounds a query to rows timestamped in [From, To)
+type TimeRange = storage.TimeRange
@@ -0,0 +1,215 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// HeaderRedrivenFrom marks messages re-driven from the DLQ, as "partition/offset"
+const HeaderRedrivenFrom = "x-dlq-redriven-from"
+
+// readTimeout bounds each read of the dead-letter topic
+const readTimeout = 5 * time.Second
+
+// Entry is a dead-lettered message as shown by the admin API. Every
+// consumer group rejecting a message dead-letters its own copy; Recent
+// lists them as one entry, naming each group in Consumers.
+type Entry struct {
+	Partition       int               `json:"partition"`
+	Offset          int64             `json:"offset"`
+	Reason          string            `json:"reason"`
+	SourceTopic     string            `json:"source_topic"`
+	SourcePartition string            `json:"source_partition"`
+	SourceOffset    string            `json:"source_offset"`
+	Consumer        string            `json:"consumer"`
+	Consumers       []string          `json:"consumers"`
+	FailedAt        string            `json:"failed_at"`
+	Key             string            `json:"key"`
+	Size            int               `json:"size"`
+	Headers         map[string]string `json:"headers,omitempty"` // original headers
+	msg             bus.Message
+	failedAt        time.Time
+}
+
+// Inspector reads the dead-letter topic and re-drives messages from it
+type Inspector struct {
+	topic string
+	bus   bus.Bus
+}
+
+func NewInspector(b bus.Bus, topic string) *Inspector {
+	return &Inspector{topic: topic, bus: b}
+}
+
+func (i *Inspector) Topic() string {
+	return i.topic
+}
+
+// Recent returns up to limit of the newest DLQ messages, newest first.
+// Copies of one source message rejected by several consumer groups are
+// listed once, as the newest copy.
+func (i *Inspector) Recent(limit int) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+
+	partitions, err := i.bus.Offsets(ctx, i.topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, p := range partitions {
+		start := max(p.End-int64(limit), p.First)
+		err := i.bus.Read(ctx, i.topic, p.Partition, start, p.End, func(m bus.Message) error {
+			entries = append(entries, *newEntry(m))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		if !entries[a].failedAt.Equal(entries[b].failedAt) {
+			return entries[a].failedAt.After(entries[b].failedAt)
+		}
+		if entries[a].Partition != entries[b].Partition {
+			return entries[a].Partition > entries[b].Partition
+		}
+		return entries[a].Offset > entries[b].Offset
+	})
+
+	listed := make(map[string]int) // source position → index in unique
+	var unique []Entry
+	for _, e := range entries {
+		source, ok := e.source()
+		if j, seen := listed[source]; ok && seen {
+			unique[j].Consumers = append(unique[j].Consumers, e.Consumer)
+			continue
+		}
+		if ok {
+			listed[source] = len(unique)
+		}
+		unique = append(unique, e)
+	}
+	if len(unique) > limit {
+		unique = unique[:limit]
+	}
+	return unique, nil
+}
+
+// Get reads a single DLQ message
+func (i *Inspector) Get(partition int, offset int64) (*Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+
+	var entry *Entry
+	err := i.bus.Read(ctx, i.topic, partition, offset, offset+1, func(m bus.Message) error {
+		entry = newEntry(m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || entry.Offset != offset {
+		// compacted or deleted
+		return nil, fmt.Errorf("offset %d not found in partition %d", offset, partition)
+	}
+	return entry, nil
+}
+
+// Redrive publishes a DLQ message back to its source topic with its
+// original key and headers. An event without an ID keeps the one it was
+// given at its source position, so consumers that stored it before skip
+// it.
+func (i *Inspector) Redrive(partition int, offset int64) error {
+	e, err := i.Get(partition, offset)
+	if err != nil {
+		return err
+	}
+	if e.SourceTopic == "" {
+		return errors.New("message has no source topic header")
+	}
+
+	var headers []bus.Header
+	for _, h := range e.msg.Headers {
+		if !producer.IsDeadLetterHeader(h.Key) && h.Key != HeaderRedrivenFrom {
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers, bus.Header{
+		Key:   HeaderRedrivenFrom,
+		Value: []byte(strconv.Itoa(partition) + "/" + strconv.FormatInt(offset, 10)),
+	})
+	if e.msg.Header(schema.HeaderEventID) == "" {
+		sourcePartition, perr := strconv.Atoi(e.SourcePartition)
+		sourceOffset, oerr := strconv.ParseInt(e.SourceOffset, 10, 64)
+		if perr == nil && oerr == nil {
+			headers = append(headers, bus.Header{
+				Key:   schema.HeaderEventID,
+				Value: []byte(schema.PositionEventID(e.SourceTopic, sourcePartition, sourceOffset)),
+			})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+	return i.bus.Publish(ctx, e.SourceTopic, bus.Message{
+		Key:     e.msg.Key,
+		Value:   e.msg.Value,
+		Headers: headers,
+	})
+}
+
+func newEntry(msg bus.Message) *Entry {
+	e := &Entry{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Size:      len(msg.Value),
+		msg:       msg,
+	}
+	for _, h := range msg.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case producer.HeaderDLQReason:
+			e.Reason = value
+		case producer.HeaderDLQSourceTopic:
+			e.SourceTopic = value
+		case producer.HeaderDLQSourcePartition:
+			e.SourcePartition = value
+		case producer.HeaderDLQSourceOffset:
+			e.SourceOffset = value
+		case producer.HeaderDLQConsumer:
+			e.Consumer = value
+			e.Consumers = []string{value}
+		case producer.HeaderDLQFailedAt:
+			e.FailedAt = value
+			// RFC3339Nano parses the second-resolution times of older
+			// records too
+			e.failedAt, _ = time.Parse(time.RFC3339Nano, value)
+		default:
+			if e.Headers == nil {
+				e.Headers = make(map[string]string)
+			}
+			e.Headers[string(h.Key)] = value
+		}
+	}
+	return e
+}
+
+// source is the position of the message in its source topic, if known
+func (e *Entry) source() (string, bool) {
+	if e.SourceTopic == "" || e.SourceOffset == "" {
+		return "", false
+	}
+	return e.SourceTopic + "/" + e.SourcePartition + "/" + e.SourceOffset, true
+}
@@ -0,0 +1,119 @@
+package dlq
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+func TestInspectorRedrive(t *testing.T) {
+	ctx := context.Background()
+	b := bus.NewMemory(1)
+	defer b.Close()
+
+	dlq := producer.New(b, "events-dlq")
+	for i, value := range []string{"not json", "{bad"} {
+		err := dlq.PublishDeadLetter(ctx, producer.DeadLetter{
+			Topic:    "events",
+			Offset:   int64(i),
+			Key:      []byte("checkout"),
+			Value:    []byte(value),
+			Headers:  []bus.Header{{Key: "x-tenant-id", Value: []byte("acme")}},
+			Consumer: "contextify-service",
+			Reason:   "invalid JSON",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inspector := NewInspector(b, "events-dlq")
+	entries, err := inspector.Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Recent() returned %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Reason != "invalid JSON" || e.SourceTopic != "events" || e.Consumer != "contextify-service" || e.Headers["x-tenant-id"] != "acme" {
+		t.Errorf("Recent()[0] = %+v", e)
+	}
+	if entries, err := inspector.Recent(1); err != nil || len(entries) != 1 {
+		t.Errorf("Recent(1) = %d entries, %v; want 1", len(entries), err)
+	}
+
+	if _, err := inspector.Get(0, 5); err == nil {
+		t.Error("Get of a missing offset succeeded")
+	}
+
+	if err := inspector.Redrive(0, 1); err != nil {
+		t.Fatalf("Redrive: %v", err)
+	}
+	var redriven []bus.Message
+	err = b.Read(ctx, "events", 0, 0, 10, func(m bus.Message) error {
+		redriven = append(redriven, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redriven) != 1 {
+		t.Fatalf("source topic holds %d messages, want 1", len(redriven))
+	}
+	m := redriven[0]
+	if string(m.Value) != "{bad" || string(m.Key) != "checkout" {
+		t.Errorf("redriven %q with key %q, want %q with key checkout", m.Value, m.Key, "{bad")
+	}
+	if m.Header("x-tenant-id") != "acme" || m.Header(HeaderRedrivenFrom) != "0/1" || m.Header(producer.HeaderDLQReason) != "" {
+		t.Errorf("redriven headers = %+v", m.Headers)
+	}
+	// An event without an ID keeps the one its source position gave it
+	if id := m.Header(schema.HeaderEventID); id != "events-0-1" {
+		t.Errorf("redriven event ID header = %q, want events-0-1", id)
+	}
+}
+
+func TestInspectorListsEachSourceOnce(t *testing.T) {
+	ctx := context.Background()
+	b := bus.NewMemory(2)
+	defer b.Close()
+
+	dlq := producer.New(b, "events-dlq")
+	deadLetter := func(offset int64, consumer string) {
+		t.Helper()
+		err := dlq.PublishDeadLetter(ctx, producer.DeadLetter{
+			Topic:    "events",
+			Offset:   offset,
+			Key:      []byte("checkout-" + strconv.FormatInt(offset, 10)),
+			Value:    []byte("{bad"),
+			Consumer: consumer,
+			Reason:   "invalid JSON",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Both groups reject offset 1 within the same second; only the
+	// anomaly service rejects offset 2, after them
+	deadLetter(1, "contextify-service")
+	deadLetter(1, "anomaly-service")
+	deadLetter(2, "anomaly-service")
+
+	entries, err := NewInspector(b, "events-dlq").Recent(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Recent() returned %d entries, want one per source message: %+v", len(entries), entries)
+	}
+	if entries[0].SourceOffset != "2" || entries[1].SourceOffset != "1" {
+		t.Errorf("Recent() lists source offsets %s, %s; want newest first", entries[0].SourceOffset, entries[1].SourceOffset)
+	}
+	if got := entries[1].Consumers; len(got) != 2 || got[0] != "anomaly-service" || got[1] != "contextify-service" {
+		t.Errorf("offset 1 rejected by %v, want both groups, newest first", got)
+	}
+}
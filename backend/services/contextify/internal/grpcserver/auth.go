@@ -0,0 +1,60 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate resolves the caller's tenant from the API key in its
+// metadata, sent as "x-api-key" or "authorization: Bearer <key>"
+func authenticate(ctx context.Context, keys tenant.Keys) (context.Context, error) {
+	var key string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(strings.ToLower(tenant.HeaderAPIKey)); len(v) > 0 {
+		key = v[0]
+	} else if v := md.Get("authorization"); len(v) > 0 && len(v[0]) > 7 && strings.EqualFold(v[0][:7], "Bearer ") {
+		key = strings.TrimSpace(v[0][7:])
+	}
+
+	t, ok := keys.Lookup(key)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+	}
+	return tenant.NewContext(ctx, t), nil
+}
+
+func unaryAuth(keys tenant.Keys) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(keys tenant.Keys) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), keys)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// tenantStream is a server stream whose context carries the caller's tenant
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
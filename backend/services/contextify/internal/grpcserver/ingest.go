@@ -0,0 +1,97 @@
+package grpcserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	pb "github.com/sujal-lgtm/Contextify/backend/services/contextify/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Ingest is what IngestEvents needs to validate and forward events
+type Ingest struct {
+	Publisher *producer.Publisher
+	Rules     schema.Rules
+	MaxBatch  int // events per batch
+	Quotas    *pipeline.Quotas
+}
+
+// IngestEvents validates each batch, publishes its valid events to the
+// events topic, and acks it before reading the next one. Events belong to
+// the caller's tenant; those over their service's quota are reported as
+// rejected.
+func (s *server) IngestEvents(stream pb.ContextifyService_IngestEventsServer) error {
+	ctx := stream.Context()
+	caller := tenant.FromContext(ctx)
+	var batches, events int
+	defer func() {
+		logrus.WithFields(logrus.Fields{
+			"batches": batches,
+			"events":  events,
+		}).Info("📥 Ingest stream closed")
+	}()
+
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(batch.GetEvents()) > s.ingest.MaxBatch {
+			return status.Errorf(codes.InvalidArgument, "batch %d: %d events exceeds limit of %d",
+				batch.GetBatchId(), len(batch.GetEvents()), s.ingest.MaxBatch)
+		}
+
+		ack := &pb.BatchAck{BatchId: batch.GetBatchId()}
+		valid := make([]schema.Event, 0, len(batch.GetEvents()))
+		var grants []pipeline.QuotaGrant
+		for i, pe := range batch.GetEvents() {
+			e := schema.FromProto(pe)
+			if err := s.ingest.Rules.Validate(e, proto.Size(pe)); err != nil {
+				ack.Rejected = append(ack.Rejected, &pb.EventRejection{Index: uint32(i), Reason: err.Error()})
+				continue
+			}
+			if e.EventID == "" {
+				e.EventID = schema.NewEventID()
+			}
+			e.TenantID = caller
+			grant, ok := s.ingest.Quotas.Allow(e.TenantID, e.Service, e.TraceID, e.EventID)
+			if !ok {
+				ack.Rejected = append(ack.Rejected, &pb.EventRejection{Index: uint32(i), Reason: fmt.Sprintf("service %q is over its ingestion quota", e.Service)})
+				continue
+			}
+			valid = append(valid, *e)
+			grants = append(grants, grant)
+		}
+
+		if len(valid) > 0 {
+			if err := s.ingest.Publisher.PublishBatch(ctx, valid); err != nil {
+				// The client resends unacked batches on a new stream, so
+				// the quota they took is given back
+				for _, g := range grants {
+					s.ingest.Quotas.Refund(g)
+				}
+				logrus.Errorf("Failed to publish ingest batch %d: %v", batch.GetBatchId(), err)
+				return status.Error(codes.Unavailable, fmt.Sprintf("batch %d: publish failed", batch.GetBatchId()))
+			}
+		}
+		ack.Accepted = uint32(len(valid))
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+		batches++
+		events += len(valid)
+	}
+}
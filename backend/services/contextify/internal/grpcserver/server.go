@@ -5,28 +5,38 @@ import (
 	"net"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/otlp"
 	pb "github.com/sujal-lgtm/Contextify/backend/services/contextify/proto"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
 type server struct {
 	pb.UnimplementedContextifyServiceServer
+	ingest Ingest
 }
 
 func (s *server) Ping(ctx context.Context, req *pb.PingRequest) (*pb.PingResponse, error) {
 	return &pb.PingResponse{Reply: "Pong: " + req.Message}, nil
 }
 
-func StartGrpcServer(port string) func() {
+// StartGrpcServer serves the Contextify API and, on the same port, the
+// OTLP trace service. Callers present one of keys, when any are set.
+func StartGrpcServer(port string, ingest Ingest, receiver *otlp.Receiver, keys tenant.Keys) func() {
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		logrus.Fatalf("Failed to listen on port %s: %v", port, err)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryAuth(keys)),
+		grpc.StreamInterceptor(streamAuth(keys)),
+	)
 	reflection.Register(grpcServer)
-	pb.RegisterContextifyServiceServer(grpcServer, &server{})
+	pb.RegisterContextifyServiceServer(grpcServer, &server{ingest: ingest})
+	coltracepb.RegisterTraceServiceServer(grpcServer, receiver)
 
 	go func() {
 		logrus.Infof("gRPC server listening on :%s", port)
@@ -3,12 +3,19 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
 )
 
-// GET /anomalies?service=X&limit_anomalies=N&limit_context=N
+// GET /anomalies?service=X&limit_anomalies=N&limit_context=N&from=T&to=T&attr.K=V
+//
+// from and to, RFC3339 or unix millis, narrow both lists to that range
 func (h *Handlers) GetAnomaliesByService(w http.ResponseWriter, r *http.Request) {
 	service := r.URL.Query().Get("service")
 	if service == "" {
@@ -19,15 +26,28 @@ func (h *Handlers) GetAnomaliesByService(w http.ResponseWriter, r *http.Request)
 	// Parse limits
 	limitAnomalies := parseLimit(r.URL.Query().Get("limit_anomalies"), 20)
 	limitContext := parseLimit(r.URL.Query().Get("limit_context"), 20)
+	attrs := parseAttrFilter(r.URL.Query())
+	caller := tenant.FromContext(r.Context())
 
-	anomalies, err := h.DB.GetRecentAnomalies(service, limitAnomalies)
+	var span db.TimeRange
+	var err error
+	if span.From, err = parseTime(r.URL.Query().Get("from"), time.Time{}); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid from param")
+		return
+	}
+	if span.To, err = parseTime(r.URL.Query().Get("to"), time.Time{}); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to param")
+		return
+	}
+
+	anomalies, err := h.DB.GetRecentAnomalies(caller, service, span, attrs, limitAnomalies)
 	if err != nil {
 		logrus.WithError(err).Error("DB query failed for anomalies")
 		writeError(w, http.StatusInternalServerError, "failed to fetch anomalies")
 		return
 	}
 
-	contexts, err := h.DB.GetRecentEvents(service, limitContext)
+	contexts, err := h.DB.GetRecentEvents(caller, service, span, attrs, limitContext)
 	if err != nil {
 		logrus.WithError(err).Error("DB query failed for context events")
 		writeError(w, http.StatusInternalServerError, "failed to fetch context")
@@ -58,6 +78,29 @@ func parseLimit(raw string, def int) int {
 	return def
 }
 
+// parseAttrFilter collects attr.K=V query parameters, first value wins
+func parseAttrFilter(q url.Values) db.AttrFilter {
+	attrs := db.AttrFilter{}
+	for key, values := range q {
+		name := strings.TrimPrefix(key, "attr.")
+		if name == key || name == "" || len(values) == 0 {
+			continue
+		}
+		attrs[name] = values[0]
+	}
+	return attrs
+}
+
+// requireOperator answers 403 unless the caller acts for the operator's
+// tenant, for endpoints that span tenants
+func requireOperator(w http.ResponseWriter, r *http.Request) bool {
+	if !tenant.IsOperator(r.Context()) {
+		writeError(w, http.StatusForbidden, "admin endpoints are reserved to the operator")
+		return false
+	}
+	return true
+}
+
 func writeError(w http.ResponseWriter, code int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -7,9 +7,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
 )
 
-// GET /context/{trace_id}?limit=N
+// GET /context/{trace_id}?limit=N&attr.K=V
+//
+// Only the caller's tenant's events are returned
 func (h *Handlers) GetContextByTraceID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	traceID := vars["trace_id"]
@@ -29,7 +32,7 @@ func (h *Handlers) GetContextByTraceID(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	events, err := h.DB.GetContextsByTraceID(traceID, limit)
+	events, err := h.DB.GetContextsByTraceID(tenant.FromContext(r.Context()), traceID, parseAttrFilter(r.URL.Query()), limit)
 	if err != nil {
 		logrus.WithError(err).Error("DB query failed for trace_id")
 		writeError(w, http.StatusInternalServerError, "failed to fetch context")
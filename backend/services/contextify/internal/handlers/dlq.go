@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+type RedriveRequest struct {
+	Messages []struct {
+		Partition int   `json:"partition"`
+		Offset    int64 `json:"offset"`
+	} `json:"messages"`
+}
+
+// GET /admin/dlq?limit=N
+func (h *Handlers) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !requireOperator(w, r) {
+		return
+	}
+	if h.DLQ == nil {
+		writeError(w, http.StatusServiceUnavailable, "dead-letter topic unavailable")
+		return
+	}
+
+	limit := parseLimit(r.URL.Query().Get("limit"), 50)
+
+	entries, err := h.DLQ.Recent(limit)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read dead-letter topic")
+		writeError(w, http.StatusInternalServerError, "failed to read dead-letter topic")
+		return
+	}
+
+	// Summarise why messages were rejected
+	reasons := make(map[string]int)
+	for _, e := range entries {
+		reasons[e.Reason]++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"topic":   h.DLQ.Topic(),
+		"count":   len(entries),
+		"reasons": reasons,
+		"entries": entries,
+	})
+}
+
+// POST /admin/dlq/redrive {"messages":[{"partition":0,"offset":12}]}
+func (h *Handlers) RedriveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !requireOperator(w, r) {
+		return
+	}
+	if h.DLQ == nil {
+		writeError(w, http.StatusServiceUnavailable, "dead-letter topic unavailable")
+		return
+	}
+
+	var req RedriveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "expected a non-empty messages list")
+		return
+	}
+
+	results := make([]map[string]interface{}, 0, len(req.Messages))
+	redriven := 0
+	for _, m := range req.Messages {
+		result := map[string]interface{}{
+			"partition": m.Partition,
+			"offset":    m.Offset,
+		}
+		if err := h.DLQ.Redrive(m.Partition, m.Offset); err != nil {
+			logrus.WithError(err).Errorf("Failed to redrive DLQ message %d/%d", m.Partition, m.Offset)
+			result["success"] = false
+			result["error"] = err.Error()
+		} else {
+			result["success"] = true
+			redriven++
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"redriven": redriven,
+		"failed":   len(req.Messages) - redriven,
+		"results":  results,
+	})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/bus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+)
+
+// EventIngest is what POST /events needs to validate and forward events
+type EventIngest struct {
+	Publisher    *producer.Publisher
+	Rules        schema.Rules
+	MaxBatch     int   // events per request
+	MaxBodyBytes int64 // after decompression
+	Quotas       *pipeline.Quotas
+}
+
+// EventResult reports what happened to one event of a request, by its
+// position in the body
+type EventResult struct {
+	Index   int    `json:"index"`
+	EventID string `json:"event_id,omitempty"`
+	Status  string `json:"status"` // accepted, rejected, throttled or failed
+	Error   string `json:"error,omitempty"`
+}
+
+// POST /events
+//
+// Content-Type application/json carries one event, application/x-ndjson
+// one event per line. Bodies may be gzip-compressed. Valid events are
+// published to the events topic unless their service is over its quota;
+// the response lists every event's result. Events belong to the caller's
+// tenant, whatever their tenant_id field says.
+func (h *Handlers) IngestEvents(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if contentType != "application/json" && contentType != "application/x-ndjson" {
+		writeError(w, http.StatusUnsupportedMediaType, "expected application/json or application/x-ndjson")
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, h.Events.MaxBodyBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	if int64(len(data)) > h.Events.MaxBodyBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", h.Events.MaxBodyBytes))
+		return
+	}
+
+	var lines [][]byte
+	if contentType == "application/json" {
+		lines = [][]byte{data}
+	} else {
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		writeError(w, http.StatusBadRequest, "no events in body")
+		return
+	}
+	if len(lines) > h.Events.MaxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch of %d events exceeds limit of %d", len(lines), h.Events.MaxBatch))
+		return
+	}
+
+	caller := tenant.FromContext(r.Context())
+	results := make([]EventResult, len(lines))
+	var events []schema.Event
+	var positions []int // index in results of each entry in events
+	var grants []pipeline.QuotaGrant
+	for i, line := range lines {
+		results[i].Index = i
+		e, err := h.Events.Rules.DecodeAndValidate(schema.ContentTypeJSON, line)
+		if err != nil {
+			results[i].Status = "rejected"
+			results[i].Error = err.Error()
+			continue
+		}
+		if e.EventID == "" {
+			e.EventID = schema.NewEventID()
+		}
+		e.TenantID = caller
+		results[i].EventID = e.EventID
+		grant, ok := h.Events.Quotas.Allow(e.TenantID, e.Service, e.TraceID, e.EventID)
+		if !ok {
+			results[i].Status = "throttled"
+			results[i].Error = fmt.Sprintf("service %q is over its ingestion quota", e.Service)
+			continue
+		}
+		results[i].Status = "accepted"
+		events = append(events, *e)
+		positions = append(positions, i)
+		grants = append(grants, grant)
+	}
+
+	if len(events) > 0 {
+		err := h.Events.Publisher.PublishBatch(r.Context(), events)
+		var writeErrs bus.WriteErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &writeErrs):
+			for j, werr := range writeErrs {
+				if werr != nil {
+					results[positions[j]].Status = "failed"
+					results[positions[j]].Error = werr.Error()
+					h.Events.Quotas.Refund(grants[j])
+				}
+			}
+		default:
+			logrus.WithError(err).Error("Failed to publish ingested events")
+			for j, i := range positions {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+				h.Events.Quotas.Refund(grants[j])
+			}
+		}
+	}
+
+	counts := map[string]int{"accepted": 0, "rejected": 0, "throttled": 0, "failed": 0}
+	for _, res := range results {
+		counts[res.Status]++
+	}
+
+	code := http.StatusOK
+	switch {
+	case counts["failed"] > 0 && counts["accepted"] == 0:
+		code = http.StatusServiceUnavailable
+	case counts["accepted"] == 0 && counts["throttled"] > 0:
+		code = http.StatusTooManyRequests
+	case counts["accepted"] == 0:
+		code = http.StatusBadRequest
+	case counts["accepted"] < len(results):
+		code = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accepted":  counts["accepted"],
+		"rejected":  counts["rejected"],
+		"throttled": counts["throttled"],
+		"failed":    counts["failed"],
+		"results":   results,
+	})
+}
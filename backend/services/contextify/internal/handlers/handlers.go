@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
 	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/dlq"
 )
 
 type Handlers struct {
-	DB *db.DB
+	DB           db.Store
+	DLQ          *dlq.Inspector // nil disables the dead-letter endpoints
+	Ingest       *pipeline.IngestStatus
+	LagThreshold int64
+	Events       EventIngest
+	Sampler      *pipeline.Sampler
+	Retention    map[string]int // days kept per table
 }
 
-func NewHandlers(database *db.DB) *Handlers {
-	return &Handlers{DB: database}
+func NewHandlers(store db.Store, inspector *dlq.Inspector, ingest *pipeline.IngestStatus, lagThreshold int64, events EventIngest, sampler *pipeline.Sampler, retention map[string]int) *Handlers {
+	return &Handlers{DB: store, DLQ: inspector, Ingest: ingest, LagThreshold: lagThreshold, Events: events, Sampler: sampler, Retention: retention}
 }
@@ -6,38 +6,36 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
 
 	"github.com/sirupsen/logrus"
 )
 
-var (
-	publisher = producer.NewPublisher([]string{"kafka:9092"}, "contextify-events")
-)
-
 type IncidentRequest struct {
 	Service string `json:"service"`
 	Error   string `json:"error"`
 	Latency int64  `json:"latency_ms"`
 }
 
-func StartIncidentHandler(w http.ResponseWriter, r *http.Request) {
+// StartIncidentHandler publishes an incident start event to the events topic
+func (h *Handlers) StartIncidentHandler(w http.ResponseWriter, r *http.Request) {
 	var req IncidentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	// Build event for Kafka
-	event := map[string]interface{}{
-		"service":  req.Service,
-		"error":    req.Error,
-		"latency":  req.Latency,
-		"detected": time.Now().UTC(),
-	}
+	// Build event for the events topic
+	event := schema.NewEvent(req.Service)
+	event.TenantID = tenant.FromContext(r.Context())
+	event.Status = "incident"
+	event.Message = req.Error
+	event.LatencyMs = int(req.Latency)
+	event.Extra = map[string]json.RawMessage{"action": json.RawMessage(`"start"`)}
 
-	// Publish event to Kafka
-	if err := publisher.PublishEvent(context.Background(), req.Service, event); err != nil {
+	// Publish event to the events topic
+	if err := h.Events.Publisher.Publish(context.Background(), event); err != nil {
 		logrus.Errorf("failed to publish incident event: %v", err)
 		http.Error(w, "failed to publish event", http.StatusInternalServerError)
 		return
@@ -51,24 +49,24 @@ func StartIncidentHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func StopIncidentHandler(w http.ResponseWriter, r *http.Request) {
+// StopIncidentHandler publishes an incident stop event to the events topic
+func (h *Handlers) StopIncidentHandler(w http.ResponseWriter, r *http.Request) {
 	var req IncidentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	// Build event for Kafka
-	event := map[string]interface{}{
-		"service":  req.Service,
-		"error":    req.Error,
-		"latency":  req.Latency,
-		"resolved": time.Now().UTC(),
-		"action":   "stop",
-	}
+	// Build event for the events topic
+	event := schema.NewEvent(req.Service)
+	event.TenantID = tenant.FromContext(r.Context())
+	event.Status = "resolved"
+	event.Message = req.Error
+	event.LatencyMs = int(req.Latency)
+	event.Extra = map[string]json.RawMessage{"action": json.RawMessage(`"stop"`)}
 
-	// Publish event to Kafka
-	if err := publisher.PublishEvent(context.Background(), req.Service, event); err != nil {
+	// Publish event to the events topic
+	if err := h.Events.Publisher.Publish(context.Background(), event); err != nil {
 		logrus.Errorf("failed to publish incident stop event: %v", err)
 		http.Error(w, "failed to publish event", http.StatusInternalServerError)
 		return
@@ -82,9 +80,10 @@ func StopIncidentHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func MetricsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+// MetricsHandler reports the service's health; the batch and sampling
+// counters span tenants and are left out for anyone but the operator
+func (h *Handlers) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	metrics := map[string]interface{}{
 		"status":    "ok",
 		"service":   "contextify",
 		"timestamp": time.Now().UTC(),
@@ -94,5 +93,12 @@ func MetricsHandler(w http.ResponseWriter, r *http.Request) {
 			"incident_stop":  "/incident/stop",
 			"metrics":        "/metrics",
 		},
-	})
+	}
+	if tenant.IsOperator(r.Context()) {
+		metrics["ingest"] = h.DB.BatchStats()
+		metrics["sampling"] = h.Sampler.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
 }
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+)
+
+// GET /ingest/status
+//
+// The consumer's progress, which spans tenants: the operator's alone.
+func (h *Handlers) IngestStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireOperator(w, r) {
+		return
+	}
+	snap := h.Ingest.Snapshot()
+
+	lagging := []int32{}
+	for _, p := range snap.Partitions {
+		if p.Lag > h.LagThreshold {
+			lagging = append(lagging, p.Partition)
+		}
+	}
+
+	status := "ok"
+	if len(lagging) > 0 {
+		status = "lagging"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":             status,
+		"service":            "contextify",
+		"lag_threshold":      h.LagThreshold,
+		"lagging_partitions": lagging,
+		"ingest":             snap,
+		"timestamp":          time.Now().UTC(),
+	})
+}
+
+// GET /quotas?service=checkout
+//
+// Each service's ingestion limits, the room left under them, and how
+// many of its events were accepted, dropped or sampled over quota today
+// and since startup. Callers see their own tenant's services; the
+// operator sees every tenant's.
+func (h *Handlers) QuotasHandler(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	caller := tenant.FromContext(r.Context())
+
+	quotas := []pipeline.QuotaUsage{}
+	overQuota := []string{}
+	for _, u := range h.Events.Quotas.Usage() {
+		if service != "" && u.Service != service {
+			continue
+		}
+		if u.Tenant != caller && !tenant.IsOperator(r.Context()) {
+			continue
+		}
+		quotas = append(quotas, u)
+		if u.Today.Excess > 0 {
+			overQuota = append(overQuota, u.Service)
+		}
+	}
+
+	limits, defaultLimit := h.Events.Quotas.Limits()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"limits":        limits,
+		"default_limit": defaultLimit,
+		"quotas":        quotas,
+		"over_quota":    overQuota,
+		"timestamp":     time.Now().UTC(),
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+// GET /admin/partitions
+//
+// The daily partitions of each partitioned table with their sizes, and
+// how many days of it are kept
+func (h *Handlers) ListPartitions(w http.ResponseWriter, r *http.Request) {
+	if !requireOperator(w, r) {
+		return
+	}
+
+	partitions, err := h.DB.Partitions()
+	if errors.Is(err, db.ErrNoPartitions) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list partitions")
+		writeError(w, http.StatusInternalServerError, "failed to list partitions")
+		return
+	}
+
+	type tableSize struct {
+		RetentionDays int            `json:"retention_days"` // 0 keeps everything
+		Count         int            `json:"partition_count"`
+		Rows          int64          `json:"rows_estimate"`
+		Bytes         int64          `json:"bytes"`
+		Partitions    []db.Partition `json:"partitions"`
+	}
+	tables := make(map[string]*tableSize, len(db.PartitionedTables))
+	for _, table := range db.PartitionedTables {
+		tables[table] = &tableSize{RetentionDays: h.Retention[table], Partitions: []db.Partition{}}
+	}
+	for _, p := range partitions {
+		t := tables[p.Table]
+		t.Count++
+		t.Rows += p.Rows
+		t.Bytes += p.Bytes
+		t.Partitions = append(t.Partitions, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tables":    tables,
+		"timestamp": time.Now().UTC(),
+	})
+}
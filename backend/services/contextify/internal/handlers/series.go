@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+const (
+	// maxSeriesPoints caps the points of one series; longer ranges get a
+	// wider step
+	maxSeriesPoints = 1500
+
+	// maxMinuteRange is the longest range read from 1-minute rollups
+	maxMinuteRange = 24 * time.Hour
+)
+
+// seriesMetrics are the metrics /series serves
+var seriesMetrics = map[string]bool{
+	"count": true, "error_count": true, "error_rate": true,
+	"latency_min": true, "latency_avg": true, "latency_max": true,
+	"latency_p50": true, "latency_p95": true, "latency_p99": true,
+	"queue_length_max": true,
+}
+
+// averagedMetrics can't be merged exactly across buckets; steps wider than
+// a bucket get the count-weighted mean of the buckets' values
+var averagedMetrics = map[string]bool{
+	"latency_avg": true, "latency_p50": true, "latency_p95": true, "latency_p99": true,
+}
+
+// SeriesPoint is one step of a series
+type SeriesPoint struct {
+	T int64   `json:"t"` // step start, unix millis
+	V float64 `json:"v"`
+}
+
+// GET /series?service=checkout&metric=latency_p95&step=5m&from=...&to=...
+//
+// A metric of the service's contexts over time, read from the 1-minute or
+// 1-hour rollups, whichever fits the range and step. from and to are
+// RFC3339 or unix millis, and default to the last hour; step defaults to
+// the resolution, widened to stay under maxSeriesPoints. Steps without
+// events are left out; steps averaging several buckets' percentiles are
+// flagged approximate.
+func (h *Handlers) GetSeries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	service := q.Get("service")
+	if service == "" {
+		writeError(w, http.StatusBadRequest, "missing service param")
+		return
+	}
+	metric := q.Get("metric")
+	if !seriesMetrics[metric] {
+		writeError(w, http.StatusBadRequest, "invalid metric param: want count, error_count, error_rate, "+
+			"latency_min, latency_avg, latency_max, latency_p50, latency_p95, latency_p99 or queue_length_max")
+		return
+	}
+
+	now := time.Now()
+	to, err := parseTime(q.Get("to"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid to param")
+		return
+	}
+	from, err := parseTime(q.Get("from"), to.Add(-time.Hour))
+	if err != nil || !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "invalid from param: want a time before to")
+		return
+	}
+	var step time.Duration
+	if v := q.Get("step"); v != "" {
+		if step, err = time.ParseDuration(v); err != nil || step <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid step param")
+			return
+		}
+	}
+
+	// 1-minute buckets for short, recent ranges at fine steps
+	res := db.Minute
+	minuteKept := time.Duration(h.Retention[db.Minute.Table]) * 24 * time.Hour
+	if step >= time.Hour || to.Sub(from) > maxMinuteRange || (minuteKept > 0 && from.Before(now.Add(-minuteKept))) {
+		res = db.Hour
+	}
+	if step < res.Width {
+		step = res.Width
+	}
+	if to.Sub(from)/step > maxSeriesPoints {
+		step = to.Sub(from) / maxSeriesPoints
+	}
+	if rem := step % res.Width; rem != 0 {
+		step += res.Width - rem
+	}
+
+	rollups, err := h.DB.Rollups(tenant.FromContext(r.Context()), service, res, from.Truncate(step), to)
+	if err != nil {
+		logrus.WithError(err).Error("DB query failed for rollups")
+		writeError(w, http.StatusInternalServerError, "failed to fetch series")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"service":     service,
+		"metric":      metric,
+		"resolution":  res.Name,
+		"step":        step.String(),
+		"from":        from.UTC(),
+		"to":          to.UTC(),
+		"approximate": step > res.Width && averagedMetrics[metric],
+		"points":      foldSeries(rollups, metric, step),
+	})
+}
+
+// parseTime reads RFC3339 or unix millis, def when raw is empty
+func parseTime(raw string, def time.Time) (time.Time, error) {
+	if raw == "" {
+		return def, nil
+	}
+	if millis, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// stepAcc accumulates the rollups falling into one step
+type stepAcc struct {
+	count, errors int64
+	min, max      float64
+	queueMax      int
+	weighted      float64 // sum of the metric's value times count
+	weight        int64
+}
+
+// foldSeries merges rollups, oldest first, into steps of the given width
+func foldSeries(rollups []db.Rollup, metric string, step time.Duration) []SeriesPoint {
+	width := step.Milliseconds()
+	points := []SeriesPoint{}
+	var acc *stepAcc
+	var start int64
+
+	flush := func() {
+		if acc == nil {
+			return
+		}
+		v := 0.0
+		switch metric {
+		case "count":
+			v = float64(acc.count)
+		case "error_count":
+			v = float64(acc.errors)
+		case "error_rate":
+			if acc.count > 0 {
+				v = float64(acc.errors) / float64(acc.count)
+			}
+		case "latency_min":
+			v = acc.min
+		case "latency_max":
+			v = acc.max
+		case "queue_length_max":
+			v = float64(acc.queueMax)
+		default: // averagedMetrics
+			if acc.weight > 0 {
+				v = acc.weighted / float64(acc.weight)
+			}
+		}
+		points = append(points, SeriesPoint{T: start, V: v})
+	}
+
+	for _, r := range rollups {
+		if s := r.Bucket - r.Bucket%width; acc == nil || s != start {
+			flush()
+			acc = &stepAcc{min: math.Inf(1), max: math.Inf(-1)}
+			start = s
+		}
+		acc.count += r.Count
+		acc.errors += r.ErrorCount
+		acc.min = math.Min(acc.min, r.LatencyMin)
+		acc.max = math.Max(acc.max, r.LatencyMax)
+		if r.QueueLengthMax > acc.queueMax {
+			acc.queueMax = r.QueueLengthMax
+		}
+
+		var v float64
+		switch metric {
+		case "latency_avg":
+			v = r.LatencyAvg
+		case "latency_p50":
+			v = r.LatencyP50
+		case "latency_p95":
+			v = r.LatencyP95
+		case "latency_p99":
+			v = r.LatencyP99
+		}
+		acc.weighted += v * float64(r.Count)
+		acc.weight += r.Count
+	}
+	flush()
+	return points
+}
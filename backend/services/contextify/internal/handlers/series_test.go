@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+func TestFoldSeries(t *testing.T) {
+	const minute = int64(60 * 1000)
+	rollups := []db.Rollup{
+		{Bucket: 0, Count: 1, ErrorCount: 0, LatencyMin: 10, LatencyMax: 10, LatencyP95: 10, QueueLengthMax: 3},
+		{Bucket: minute, Count: 3, ErrorCount: 3, LatencyMin: 20, LatencyMax: 90, LatencyP95: 50, QueueLengthMax: 7},
+		// 2-3 minutes had no events
+		{Bucket: 4 * minute, Count: 2, ErrorCount: 1, LatencyMin: 5, LatencyMax: 40, LatencyP95: 30, QueueLengthMax: 1},
+	}
+
+	tests := []struct {
+		metric string
+		step   time.Duration
+		want   []SeriesPoint
+	}{
+		{"count", time.Minute, []SeriesPoint{{0, 1}, {minute, 3}, {4 * minute, 2}}},
+		{"count", 2 * time.Minute, []SeriesPoint{{0, 4}, {4 * minute, 2}}},
+		{"error_count", 5 * time.Minute, []SeriesPoint{{0, 4}}},
+		{"error_rate", 2 * time.Minute, []SeriesPoint{{0, 0.75}, {4 * minute, 0.5}}},
+		{"latency_min", 5 * time.Minute, []SeriesPoint{{0, 5}}},
+		{"latency_max", 2 * time.Minute, []SeriesPoint{{0, 90}, {4 * minute, 40}}},
+		{"queue_length_max", 5 * time.Minute, []SeriesPoint{{0, 7}}},
+		// Percentiles are weighted by each bucket's count
+		{"latency_p95", 2 * time.Minute, []SeriesPoint{{0, 40}, {4 * minute, 30}}},
+	}
+	for _, tt := range tests {
+		got := foldSeries(rollups, tt.metric, tt.step)
+		if len(got) != len(tt.want) {
+			t.Errorf("foldSeries(%s, %s) = %v, want %v", tt.metric, tt.step, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("foldSeries(%s, %s) = %v, want %v", tt.metric, tt.step, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFoldSeriesEmpty(t *testing.T) {
+	if got := foldSeries(nil, "count", time.Minute); got == nil || len(got) != 0 {
+		t.Errorf("foldSeries(nil) = %#v, want an empty slice", got)
+	}
+}
@@ -0,0 +1,189 @@
+package logsource
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// Fields a Mapping can extract
+const (
+	FieldTraceID   = "trace_id"
+	FieldService   = "service"
+	FieldLevel     = "level"
+	FieldLatency   = "latency_ms"
+	FieldMessage   = "message"
+	FieldTimestamp = "timestamp"
+)
+
+var mappedFields = []string{FieldTraceID, FieldService, FieldLevel, FieldLatency, FieldMessage, FieldTimestamp}
+
+// Mapping extracts event fields from a log message, either with a regex
+// whose named groups are field names, or from a JSON message by key.
+// Other named groups and JSON keys are kept as event attributes.
+type Mapping struct {
+	regex  *regexp.Regexp
+	fields map[string]string // field -> JSON key
+}
+
+// NewMapping builds a regex mapping when pattern is set, otherwise a JSON
+// one. jsonFields renames keys as "field=key,...", e.g.
+// "trace_id=traceId,latency_ms=duration"; unmapped fields use their own
+// name as the key.
+func NewMapping(pattern, jsonFields string) (*Mapping, error) {
+	m := &Mapping{fields: make(map[string]string)}
+	for _, f := range mappedFields {
+		m.fields[f] = f
+	}
+
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("log regex: %w", err)
+		}
+		m.regex = re
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(jsonFields, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		field, key, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("log field mapping %q: expected field=key", pair)
+		}
+		if _, known := m.fields[field]; !known {
+			return nil, fmt.Errorf("log field mapping %q: unknown field %q", pair, field)
+		}
+		m.fields[field] = key
+	}
+	return m, nil
+}
+
+// Apply fills base with whatever the message yields. base carries what
+// the transport already knows (service, timestamp, status), which the
+// message overrides. Messages nothing can be extracted from become the
+// event's message as they are.
+func (m *Mapping) Apply(msg string, base schema.Event) schema.Event {
+	e := base
+	e.Message = msg
+	if base.Extra != nil {
+		e.Extra = make(map[string]json.RawMessage, len(base.Extra))
+		for k, v := range base.Extra {
+			e.Extra[k] = v
+		}
+	}
+
+	values := make(map[string]json.RawMessage)
+	if m.regex != nil {
+		match := m.regex.FindStringSubmatch(msg)
+		if match == nil {
+			return e
+		}
+		for i, name := range m.regex.SubexpNames() {
+			if name != "" && match[i] != "" {
+				values[name], _ = json.Marshal(match[i])
+			}
+		}
+	} else {
+		trimmed := strings.TrimSpace(msg)
+		if !strings.HasPrefix(trimmed, "{") || json.Unmarshal([]byte(trimmed), &values) != nil {
+			return e
+		}
+		// Key names from the mapping take the place of the field names
+		renamed := make(map[string]json.RawMessage, len(values))
+		for k, v := range values {
+			renamed[k] = v
+		}
+		for field, key := range m.fields {
+			if v, ok := values[key]; ok {
+				delete(renamed, key)
+				renamed[field] = v
+			}
+		}
+		values = renamed
+	}
+
+	for k, v := range values {
+		switch k {
+		case FieldTraceID:
+			e.TraceID = rawString(v)
+		case FieldService:
+			if s := rawString(v); s != "" {
+				e.Service = s
+			}
+		case FieldLevel:
+			e.Status = statusForLevel(rawString(v))
+			setAttr(&e, "log_level", strings.ToLower(rawString(v)))
+		case FieldLatency:
+			if ms, ok := parseLatency(v); ok {
+				e.LatencyMs = ms
+			}
+		case FieldMessage:
+			e.Message = rawString(v)
+		case FieldTimestamp:
+			if ts, err := schema.ParseTimestamp(v); err == nil && ts > 0 {
+				e.Timestamp = ts
+			}
+		default:
+			if e.Extra == nil {
+				e.Extra = make(map[string]json.RawMessage)
+			}
+			e.Extra[k] = v
+		}
+	}
+	return e
+}
+
+// statusForLevel maps a log level onto the event status the detector
+// understands: anything at error severity or above is an error
+func statusForLevel(level string) string {
+	switch strings.ToLower(level) {
+	case "err", "error", "fatal", "crit", "critical", "alert", "emerg", "emergency", "panic":
+		return "error"
+	}
+	return "success"
+}
+
+// parseLatency accepts milliseconds as a number or numeric string, or a
+// Go duration string such as "250ms" or "1.5s"
+func parseLatency(v json.RawMessage) (int, bool) {
+	var n float64
+	if err := json.Unmarshal(v, &n); err == nil {
+		return int(n), true
+	}
+	s := rawString(v)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return int(f), true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return int(d.Milliseconds()), true
+	}
+	return 0, false
+}
+
+// rawString returns a JSON string's value, or the raw JSON for other types
+func rawString(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return s
+	}
+	return string(v)
+}
+
+func setAttr(e *schema.Event, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if e.Extra == nil {
+		e.Extra = make(map[string]json.RawMessage)
+	}
+	e.Extra[key] = data
+}
@@ -0,0 +1,99 @@
+// Package logsource turns plain logs into context events: RFC5424 syslog
+// over UDP or TCP, and local log files tailed across rotations. Fields
+// are pulled out of each message by a Mapping and the resulting events
+// are published to the events topic in small batches.
+package logsource
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// Sink validates events from every source and publishes them in batches
+type Sink struct {
+	publisher *producer.Publisher
+	rules     schema.Rules
+	quotas    *pipeline.Quotas
+	events    chan schema.Event
+}
+
+func NewSink(publisher *producer.Publisher, rules schema.Rules, quotas *pipeline.Quotas) *Sink {
+	return &Sink{
+		publisher: publisher,
+		rules:     rules,
+		quotas:    quotas,
+		events:    make(chan schema.Event, 1000),
+	}
+}
+
+// Emit queues an event, blocking while the sink is backed up. Events that
+// fail validation or are over their service's quota are dropped.
+func (s *Sink) Emit(ctx context.Context, e schema.Event) {
+	if err := s.rules.Validate(&e, 0); err != nil {
+		logrus.Debugf("Dropping log event from %s: %v", e.Service, err)
+		return
+	}
+	if e.EventID == "" {
+		e.EventID = schema.NewEventID()
+	}
+	e.SetTenant("")
+	if _, ok := s.quotas.Allow(e.TenantID, e.Service, e.TraceID, e.EventID); !ok {
+		logrus.Debugf("Dropping log event from %s: over ingestion quota", e.Service)
+		return
+	}
+	select {
+	case s.events <- e:
+	case <-ctx.Done():
+	}
+}
+
+// Run publishes queued events every flushEvery, or sooner once maxBatch
+// are waiting, until ctx is cancelled
+func (s *Sink) Run(ctx context.Context, flushEvery time.Duration, maxBatch int) {
+	ticker := time.NewTicker(flushEvery)
+	defer ticker.Stop()
+
+	var batch []schema.Event
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		backoff := 200 * time.Millisecond
+		for {
+			err := s.publisher.PublishBatch(ctx, batch)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				logrus.Warnf("Dropping %d log events on shutdown: %v", len(batch), err)
+				break
+			}
+			logrus.Warnf("Retrying log event publish in %s: %v", backoff, err)
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-s.events:
+			batch = append(batch, e)
+			if len(batch) >= maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package logsource
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// maxSyslogMessage bounds a single message, for both transports
+const maxSyslogMessage = 64 << 10
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslogMessage is a parsed RFC5424 message
+type syslogMessage struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time // zero when NILVALUE
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	Params    map[string]string // structured data params, by name
+	Msg       string
+}
+
+// parseRFC5424 parses
+//
+//	<PRI>VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func parseRFC5424(line string) (*syslogMessage, error) {
+	if !strings.HasPrefix(line, "<") {
+		return nil, errors.New("missing PRI")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return nil, errors.New("malformed PRI")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return nil, errors.New("malformed PRI")
+	}
+	rest := line[end+1:]
+
+	// VERSION, TIMESTAMP, HOSTNAME, APP-NAME, PROCID, MSGID
+	header := make([]string, 6)
+	for i := range header {
+		sp := strings.IndexByte(rest, ' ')
+		if sp < 0 {
+			return nil, errors.New("truncated header")
+		}
+		header[i], rest = rest[:sp], rest[sp+1:]
+	}
+	if header[0] != "1" {
+		return nil, fmt.Errorf("unsupported version %q", header[0])
+	}
+
+	m := &syslogMessage{
+		Facility: pri / 8,
+		Severity: pri % 8,
+		Hostname: nilValue(header[2]),
+		AppName:  nilValue(header[3]),
+		ProcID:   nilValue(header[4]),
+		MsgID:    nilValue(header[5]),
+		Params:   make(map[string]string),
+	}
+	if header[1] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, header[1])
+		if err != nil {
+			return nil, fmt.Errorf("timestamp: %w", err)
+		}
+		m.Timestamp = ts
+	}
+
+	rest, err = parseStructuredData(rest, m.Params)
+	if err != nil {
+		return nil, err
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	m.Msg = strings.TrimPrefix(rest, "\ufeff") // BOM marks a UTF-8 MSG
+	return m, nil
+}
+
+// parseStructuredData reads "-" or a run of [id name="value" ...]
+// elements, storing the params, and returns what follows
+func parseStructuredData(s string, params map[string]string) (string, error) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], nil
+	}
+	for strings.HasPrefix(s, "[") {
+		i := 1
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		for i < len(s) && s[i] == ' ' {
+			// name="value"
+			i++
+			eq := strings.IndexByte(s[i:], '=')
+			if eq < 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+				return "", errors.New("malformed structured data")
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+
+			var value strings.Builder
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return "", errors.New("unterminated structured data value")
+			}
+			params[name] = value.String()
+			i++
+		}
+		if i >= len(s) || s[i] != ']' {
+			return "", errors.New("unterminated structured data element")
+		}
+		s = s[i+1:]
+	}
+	return s, nil
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// event builds the transport's view of a message: the app name as
+// service, the severity as status, and host/process details as
+// attributes. Structured data params named like event fields fill them.
+func (m *syslogMessage) event(mapping *Mapping) schema.Event {
+	base := schema.Event{
+		SchemaVersion: schema.Version,
+		Service:       m.AppName,
+		Timestamp:     time.Now().UnixMilli(),
+		Status:        "success",
+	}
+	if !m.Timestamp.IsZero() {
+		base.Timestamp = m.Timestamp.UnixMilli()
+	}
+	if m.Severity <= 3 {
+		base.Status = "error"
+	}
+	setAttr(&base, "log_level", severityNames[m.Severity])
+	setAttr(&base, "syslog_facility", m.Facility)
+	for key, v := range map[string]string{"host": m.Hostname, "proc_id": m.ProcID, "msg_id": m.MsgID} {
+		if v != "" {
+			setAttr(&base, key, v)
+		}
+	}
+	for name, v := range m.Params {
+		switch name {
+		case FieldTraceID:
+			base.TraceID = v
+		case FieldService:
+			base.Service = v
+		default:
+			setAttr(&base, name, v)
+		}
+	}
+
+	e := mapping.Apply(m.Msg, base)
+	if e.Service == "" {
+		e.Service = "syslog"
+	}
+	return e
+}
+
+// ListenSyslogUDP receives one RFC5424 message per datagram until ctx is
+// cancelled
+func ListenSyslogUDP(ctx context.Context, addr string, mapping *Mapping, sink *Sink) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	logrus.Infof("📜 Syslog listening on udp %s", addr)
+
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		handleSyslogLine(ctx, string(buf[:n]), mapping, sink)
+	}
+}
+
+// ListenSyslogTCP accepts RFC5424 over TCP, framed either by octet
+// counting (RFC6587 "LEN SP MSG") or by newlines
+func ListenSyslogTCP(ctx context.Context, addr string, mapping *Mapping, sink *Sink) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	logrus.Infof("📜 Syslog listening on tcp %s", addr)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveSyslogConn(ctx, conn, mapping, sink)
+	}
+}
+
+func serveSyslogConn(ctx context.Context, conn net.Conn, mapping *Mapping, sink *Sink) {
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := readSyslogFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				logrus.Warnf("Syslog connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		handleSyslogLine(ctx, line, mapping, sink)
+	}
+}
+
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+		if err != nil || n <= 0 || n > maxSyslogMessage {
+			return "", fmt.Errorf("invalid frame length %q", lenStr)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", err
+	}
+	if len(line) > maxSyslogMessage {
+		return "", fmt.Errorf("message longer than %d bytes", maxSyslogMessage)
+	}
+	return line, nil
+}
+
+func handleSyslogLine(ctx context.Context, line string, mapping *Mapping, sink *Sink) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	if line == "" {
+		return
+	}
+	m, err := parseRFC5424(line)
+	if err != nil {
+		logrus.Debugf("Dropping malformed syslog message: %v", err)
+		return
+	}
+	sink.Emit(ctx, m.event(mapping))
+}
@@ -0,0 +1,136 @@
+package logsource
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+)
+
+// TailFile follows a log file, emitting an event per line, until ctx is
+// cancelled. It starts at the end of the file. Rotation (the path now
+// names a different file) is handled by finishing the old file and
+// reading the new one from the start; truncation by rewinding. Events
+// default to the file's base name as service.
+func TailFile(ctx context.Context, path string, poll time.Duration, mapping *Mapping, sink *Sink) {
+	service := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	var f *os.File
+	var r *bufio.Reader
+	var info os.FileInfo
+	var offset int64
+	var partial string
+	fromStart := false
+
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	// readAvailable emits every complete line not yet read
+	readAvailable := func() {
+		for {
+			chunk, err := r.ReadString('\n')
+			offset += int64(len(chunk))
+			if err != nil {
+				partial += chunk
+				return
+			}
+			line := strings.TrimRight(partial+chunk, "\r\n")
+			partial = ""
+			if line == "" {
+				continue
+			}
+			base := schema.Event{
+				SchemaVersion: schema.Version,
+				Service:       service,
+				Timestamp:     time.Now().UnixMilli(),
+				Status:        "success",
+			}
+			e := mapping.Apply(line, base)
+			setAttr(&e, "log_file", path)
+			sink.Emit(ctx, e)
+		}
+	}
+
+	logrus.Infof("📜 Tailing %s", path)
+	for {
+		if f == nil {
+			var err error
+			f, info, err = openTail(path, fromStart)
+			if err != nil {
+				// Not there yet, or mid-rotation; anything created later
+				// is read from its start
+				fromStart = true
+				if !sleepCtx(ctx, poll) {
+					return
+				}
+				continue
+			}
+			offset, _ = f.Seek(0, io.SeekCurrent)
+			r = bufio.NewReader(f)
+			partial = ""
+		}
+
+		readAvailable()
+
+		if !sleepCtx(ctx, poll) {
+			return
+		}
+
+		current, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) || (err == nil && !os.SameFile(info, current)):
+			// Rotated: finish what was written to the old file before
+			// moving on to the new one
+			logrus.Infof("📜 %s rotated, reopening", path)
+			readAvailable()
+			f.Close()
+			f = nil
+			fromStart = true
+		case err == nil && current.Size() < offset:
+			logrus.Infof("📜 %s truncated, rewinding", path)
+			f.Seek(0, io.SeekStart)
+			r.Reset(f)
+			offset = 0
+			partial = ""
+		}
+	}
+}
+
+func openTail(path string, fromStart bool) (*os.File, os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if !fromStart {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, info, nil
+}
+
+// sleepCtx waits for d, returning false if ctx was cancelled first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,218 @@
+// Package otlp receives OpenTelemetry traces over OTLP/gRPC and OTLP/HTTP
+// and feeds each span into the pipeline as a context event.
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/pipeline"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/producer"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxBodyBytes caps an OTLP/HTTP request body after decompression
+const maxBodyBytes = 16 << 20
+
+// Receiver implements the OTLP trace service. Spans are mapped to
+// canonical events, validated, and published to the events topic, so
+// they're stored and checked for anomalies like any other event.
+type Receiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	publisher *producer.Publisher
+	rules     schema.Rules
+	quotas    *pipeline.Quotas
+}
+
+func NewReceiver(publisher *producer.Publisher, rules schema.Rules, quotas *pipeline.Quotas) *Receiver {
+	return &Receiver{publisher: publisher, rules: rules, quotas: quotas}
+}
+
+// Export is the OTLP/gRPC entry point
+func (r *Receiver) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	resp, err := r.export(ctx, req)
+	if err != nil {
+		// UNAVAILABLE tells OTLP exporters to retry
+		return nil, status.Errorf(codes.Unavailable, "publish spans: %v", err)
+	}
+	return resp, nil
+}
+
+// HandleHTTP is the OTLP/HTTP entry point (POST /v1/traces). Bodies may be
+// binary protobuf or JSON, optionally gzip-compressed.
+func (r *Receiver) HandleHTTP(w http.ResponseWriter, req *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if contentType != "application/x-protobuf" && contentType != "application/json" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := io.Reader(req.Body)
+	if strings.EqualFold(req.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxBodyBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var exportReq coltracepb.ExportTraceServiceRequest
+	if contentType == "application/json" {
+		err = unmarshalJSON(data, &exportReq)
+	} else {
+		err = proto.Unmarshal(data, &exportReq)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid OTLP payload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := r.export(req.Context(), &exportReq)
+	if err != nil {
+		logrus.Errorf("Failed to publish OTLP spans: %v", err)
+		http.Error(w, "failed to publish spans", http.StatusServiceUnavailable)
+		return
+	}
+
+	var out []byte
+	if contentType == "application/json" {
+		out, err = protojson.Marshal(resp)
+	} else {
+		out, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(out)
+}
+
+// export publishes the request's valid spans under the caller's tenant.
+// Invalid ones, and those over their service's quota, are dropped and
+// reported back as a partial success; an error means nothing was accepted.
+func (r *Receiver) export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	events := SpansToEvents(req)
+	caller := tenant.FromContext(ctx)
+
+	valid := events[:0]
+	var grants []pipeline.QuotaGrant
+	var rejected int64
+	var lastErr error
+	for _, e := range events {
+		if err := r.rules.Validate(&e, 0); err != nil {
+			rejected++
+			lastErr = err
+			continue
+		}
+		e.TenantID = caller
+		grant, ok := r.quotas.Allow(e.TenantID, e.Service, e.TraceID, e.EventID)
+		if !ok {
+			rejected++
+			lastErr = fmt.Errorf("service %q is over its ingestion quota", e.Service)
+			continue
+		}
+		valid = append(valid, e)
+		grants = append(grants, grant)
+	}
+
+	if len(valid) > 0 {
+		if err := r.publisher.PublishBatch(ctx, valid); err != nil {
+			// The exporter retries the whole request
+			for _, g := range grants {
+				r.quotas.Refund(g)
+			}
+			return nil, err
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"accepted": len(valid),
+		"rejected": rejected,
+	}).Debug("📥 OTLP spans received")
+
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+			RejectedSpans: rejected,
+			ErrorMessage:  lastErr.Error(),
+		}
+	}
+	return resp, nil
+}
+
+// unmarshalJSON decodes OTLP/JSON, whose trace and span IDs are hex
+// rather than the base64 protojson expects for bytes fields
+func unmarshalJSON(data []byte, req *coltracepb.ExportTraceServiceRequest) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := hexIDsToBase64(doc); err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, req)
+}
+
+var idFields = map[string]bool{
+	"traceId": true, "spanId": true, "parentSpanId": true,
+	"trace_id": true, "span_id": true, "parent_span_id": true,
+}
+
+func hexIDsToBase64(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if s, ok := child.(string); ok && idFields[k] {
+				raw, err := hex.DecodeString(s)
+				if err != nil {
+					return fmt.Errorf("%s: %w", k, err)
+				}
+				v[k] = base64.StdEncoding.EncodeToString(raw)
+				continue
+			}
+			if err := hexIDsToBase64(child); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if err := hexIDsToBase64(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
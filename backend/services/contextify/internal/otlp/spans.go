@@ -0,0 +1,125 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/sujal-lgtm/Contextify/backend/pkg/schema"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// unknownService is what the OTel SDKs report when service.name is unset
+const unknownService = "unknown_service"
+
+// SpansToEvents maps every span in the request to a context event:
+//
+//	trace_id      hex trace ID
+//	service       resource attribute service.name
+//	timestamp     span start
+//	latency_ms    span duration
+//	status        "error" for STATUS_CODE_ERROR, "success" otherwise
+//	message       span name
+//	event_id      trace ID + span ID, so re-exported spans deduplicate
+//
+// Resource and span attributes, the span and parent IDs and the span kind
+// become extras, with span attributes winning over resource ones.
+func SpansToEvents(req *coltracepb.ExportTraceServiceRequest) []schema.Event {
+	var events []schema.Event
+	for _, rs := range req.GetResourceSpans() {
+		resourceAttrs := rs.GetResource().GetAttributes()
+
+		service := unknownService
+		for _, kv := range resourceAttrs {
+			if kv.GetKey() == "service.name" && kv.GetValue().GetStringValue() != "" {
+				service = kv.GetValue().GetStringValue()
+			}
+		}
+
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				events = append(events, spanToEvent(service, resourceAttrs, span))
+			}
+		}
+	}
+	return events
+}
+
+func spanToEvent(service string, resourceAttrs []*commonpb.KeyValue, span *tracepb.Span) schema.Event {
+	traceID := hex.EncodeToString(span.GetTraceId())
+	spanID := hex.EncodeToString(span.GetSpanId())
+
+	e := schema.Event{
+		SchemaVersion: schema.Version,
+		EventID:       "otlp-" + traceID + "-" + spanID,
+		TraceID:       traceID,
+		Service:       service,
+		Timestamp:     int64(span.GetStartTimeUnixNano() / 1e6),
+		Status:        "success",
+		Message:       span.GetName(),
+		Extra:         make(map[string]json.RawMessage),
+	}
+	if end, start := span.GetEndTimeUnixNano(), span.GetStartTimeUnixNano(); end > start {
+		e.LatencyMs = int((end - start) / 1e6)
+	}
+	if span.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR {
+		e.Status = "error"
+	}
+
+	for _, kv := range resourceAttrs {
+		if kv.GetKey() != "service.name" {
+			setExtra(e.Extra, kv.GetKey(), anyValue(kv.GetValue()))
+		}
+	}
+	for _, kv := range span.GetAttributes() {
+		setExtra(e.Extra, kv.GetKey(), anyValue(kv.GetValue()))
+	}
+	setExtra(e.Extra, "span_id", spanID)
+	if len(span.GetParentSpanId()) > 0 {
+		setExtra(e.Extra, "parent_span_id", hex.EncodeToString(span.GetParentSpanId()))
+	}
+	setExtra(e.Extra, "span_kind", span.GetKind().String())
+	if msg := span.GetStatus().GetMessage(); msg != "" {
+		setExtra(e.Extra, "status_message", msg)
+	}
+	return e
+}
+
+func setExtra(extra map[string]json.RawMessage, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	extra[key] = data
+}
+
+// anyValue converts an OTLP attribute value to its plain JSON equivalent
+func anyValue(v *commonpb.AnyValue) interface{} {
+	switch v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.GetStringValue()
+	case *commonpb.AnyValue_BoolValue:
+		return v.GetBoolValue()
+	case *commonpb.AnyValue_IntValue:
+		return v.GetIntValue()
+	case *commonpb.AnyValue_DoubleValue:
+		return v.GetDoubleValue()
+	case *commonpb.AnyValue_BytesValue:
+		return v.GetBytesValue() // base64 in JSON
+	case *commonpb.AnyValue_ArrayValue:
+		values := v.GetArrayValue().GetValues()
+		out := make([]interface{}, len(values))
+		for i, item := range values {
+			out[i] = anyValue(item)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		out := make(map[string]interface{})
+		for _, kv := range v.GetKvlistValue().GetValues() {
+			out[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package remotewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matcher tests one label, PromQL style: =, !=, =~ or !~
+type Matcher struct {
+	Name  string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+func (m Matcher) matches(labels map[string]string) bool {
+	v := labels[m.Name]
+	switch m.Op {
+	case "=":
+		return v == m.Value
+	case "!=":
+		return v != m.Value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// Selector matches a series when all its matchers do
+type Selector []Matcher
+
+// ParseSelectors reads selectors separated by ";", each a comma-separated
+// list of matchers, e.g.
+//
+//	__name__=~"process_cpu_.*",job="api";__name__="queue_depth"
+//
+// A series is kept if any selector matches it. Regexes are anchored, as
+// in PromQL.
+func ParseSelectors(s string) ([]Selector, error) {
+	var selectors []Selector
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(part), "{"), "}"))
+		if part == "" {
+			continue
+		}
+		sel, err := parseSelector(part)
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %w", part, err)
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, nil
+}
+
+func parseSelector(s string) (Selector, error) {
+	var sel Selector
+	for s != "" {
+		opAt := strings.IndexAny(s, "=!")
+		if opAt <= 0 {
+			return nil, fmt.Errorf("expected label name before %q", s)
+		}
+		m := Matcher{Name: strings.TrimSpace(s[:opAt])}
+		s = s[opAt:]
+		for _, op := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(s, op) {
+				m.Op = op
+				s = strings.TrimSpace(s[len(op):])
+				break
+			}
+		}
+		if m.Op == "" {
+			return nil, fmt.Errorf("unknown operator in %q", s)
+		}
+
+		// Quoted value; Go escaping rules, like PromQL's
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("label %s: value must be quoted", m.Name)
+		}
+		m.Value, _ = strconv.Unquote(quoted)
+		s = strings.TrimSpace(s[len(quoted):])
+
+		if m.Op == "=~" || m.Op == "!~" {
+			if m.re, err = regexp.Compile("^(?:" + m.Value + ")$"); err != nil {
+				return nil, fmt.Errorf("label %s: %w", m.Name, err)
+			}
+		}
+		sel = append(sel, m)
+
+		if s != "" {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("expected ',' before %q", s)
+			}
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+	return sel, nil
+}
+
+// Matches reports whether the series' labels satisfy any selector. No
+// selectors means no series matches: metric_points has no retention, so
+// storing every series a Prometheus sends would grow it without bound.
+func Matches(selectors []Selector, labels map[string]string) bool {
+	for _, sel := range selectors {
+		ok := true
+		for _, m := range sel {
+			if !m.matches(labels) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
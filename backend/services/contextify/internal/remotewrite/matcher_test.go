@@ -0,0 +1,30 @@
+package remotewrite
+
+import "testing"
+
+func TestMatches(t *testing.T) {
+	labels := map[string]string{"__name__": "process_cpu_seconds_total", "job": "api"}
+	tests := []struct {
+		name      string
+		selectors string
+		want      bool
+	}{
+		{"no selectors", "", false},
+		{"equal", `__name__="process_cpu_seconds_total"`, true},
+		{"regex is anchored", `__name__=~"process_cpu"`, false},
+		{"regex", `__name__=~"process_cpu_.*",job="api"`, true},
+		{"one matcher fails", `__name__=~"process_cpu_.*",job!="api"`, false},
+		{"any selector", `__name__="queue_depth";{job="api"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selectors, err := ParseSelectors(tt.selectors)
+			if err != nil {
+				t.Fatalf("ParseSelectors(%q): %v", tt.selectors, err)
+			}
+			if got := Matches(selectors, labels); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.selectors, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package remotewrite receives Prometheus remote-write requests and
+// stores the selected series as metric points linked to services, where
+// the anomaly detector can read them alongside trace events.
+package remotewrite
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/pkg/tenant"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/remotewrite/remotewritepb"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	maxCompressedBytes   = 32 << 20
+	maxDecompressedBytes = 128 << 20
+)
+
+// Receiver handles POST /api/v1/write
+type Receiver struct {
+	db            db.MetricStore
+	selectors     []Selector
+	serviceLabels []string // first one present names the series' service
+}
+
+func NewReceiver(database db.MetricStore, selectors []Selector, serviceLabels []string) *Receiver {
+	return &Receiver{db: database, selectors: selectors, serviceLabels: serviceLabels}
+}
+
+// HandleHTTP decodes a snappy-compressed protobuf WriteRequest and stores
+// the samples of matching series. Series without a service label, and
+// non-finite samples (staleness markers), are skipped. Samples belong to
+// the caller's tenant.
+func (r *Receiver) HandleHTTP(w http.ResponseWriter, req *http.Request) {
+	compressed, err := io.ReadAll(io.LimitReader(req.Body, maxCompressedBytes+1))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(compressed) > maxCompressedBytes {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if n, err := snappy.DecodedLen(compressed); err != nil || n > maxDecompressedBytes {
+		http.Error(w, "invalid snappy body", http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, "invalid snappy body", http.StatusBadRequest)
+		return
+	}
+
+	var writeReq remotewritepb.WriteRequest
+	if err := proto.Unmarshal(data, &writeReq); err != nil {
+		http.Error(w, "invalid write request", http.StatusBadRequest)
+		return
+	}
+
+	points := r.points(tenant.FromContext(req.Context()), &writeReq)
+	if len(points) > 0 {
+		if err := r.db.SaveMetricPoints(points); err != nil {
+			// 5xx makes Prometheus retry the request
+			logrus.Errorf("Failed to store metric points: %v", err)
+			http.Error(w, "failed to store samples", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"series": len(writeReq.GetTimeseries()),
+		"stored": len(points),
+	}).Debug("📈 Remote write received")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Receiver) points(tenantID string, writeReq *remotewritepb.WriteRequest) []db.MetricPoint {
+	var points []db.MetricPoint
+	for _, ts := range writeReq.GetTimeseries() {
+		labels := make(map[string]string, len(ts.GetLabels()))
+		for _, l := range ts.GetLabels() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if !Matches(r.selectors, labels) {
+			continue
+		}
+
+		var service string
+		for _, name := range r.serviceLabels {
+			if service = labels[name]; service != "" {
+				break
+			}
+		}
+		if service == "" {
+			continue
+		}
+
+		name := labels["__name__"]
+		series := seriesKey(name, labels)
+		delete(labels, "__name__")
+
+		for _, s := range ts.GetSamples() {
+			if math.IsNaN(s.GetValue()) || math.IsInf(s.GetValue(), 0) {
+				continue
+			}
+			points = append(points, db.MetricPoint{
+				Service:   service,
+				TenantID:  tenantID,
+				Name:      name,
+				Series:    series,
+				Labels:    labels,
+				Value:     s.GetValue(),
+				Timestamp: s.GetTimestamp(),
+			})
+		}
+	}
+	return points
+}
+
+// seriesKey renders name{label="value",...} with labels sorted
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(labels[k]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+// Package retention keeps the partitioned tables to size: it creates the
+// daily partitions rows are about to land in, and drops the ones past
+// their table's retention. Rollup buckets and metric points past theirs
+// are deleted.
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+// Config holds the retention settings
+type Config struct {
+	Days        map[string]int // days kept per table; 0 or absent keeps everything
+	PremakeDays int            // days of partitions created ahead of today
+	Interval    time.Duration
+}
+
+// Run enforces retention now and then every cfg.Interval until ctx ends
+func Run(ctx context.Context, database db.RetentionStore, cfg Config) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		Enforce(database, cfg)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Enforce makes one pass over the partitioned tables. Failures are logged
+// and retried on the next pass.
+func Enforce(database db.RetentionStore, cfg Config) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	for _, table := range db.PartitionedTables {
+		created, err := database.EnsurePartitions(table, today.AddDate(0, 0, cfg.PremakeDays))
+		if err != nil {
+			logrus.Errorf("Failed to create %s partitions: %v", table, err)
+		} else if created > 0 {
+			logrus.WithFields(logrus.Fields{"table": table, "created": created}).Info("🗓️ Created partitions")
+		}
+
+		days := cfg.Days[table]
+		if days <= 0 {
+			continue
+		}
+		dropped, err := database.DropBefore(table, today.AddDate(0, 0, -days))
+		if err != nil {
+			logrus.Errorf("Failed to enforce %s retention: %v", table, err)
+		}
+		if len(dropped) > 0 {
+			logrus.WithFields(logrus.Fields{
+				"table":          table,
+				"retention_days": days,
+				"dropped":        dropped,
+			}).Info("🧹 Dropped expired partitions")
+		}
+	}
+
+	for _, res := range db.Resolutions {
+		days := cfg.Days[res.Table]
+		if days <= 0 {
+			continue
+		}
+		deleted, err := database.DeleteRollupsBefore(res, today.AddDate(0, 0, -days))
+		if err != nil {
+			logrus.Errorf("Failed to enforce %s rollup retention: %v", res.Name, err)
+		} else if deleted > 0 {
+			logrus.WithFields(logrus.Fields{
+				"resolution":     res.Name,
+				"retention_days": days,
+				"deleted":        deleted,
+			}).Info("🧹 Deleted expired rollups")
+		}
+	}
+
+	if days := cfg.Days["metric_points"]; days > 0 {
+		deleted, err := database.DeleteMetricPointsBefore(today.AddDate(0, 0, -days))
+		if err != nil {
+			logrus.Errorf("Failed to enforce metric_points retention: %v", err)
+		} else if deleted > 0 {
+			logrus.WithFields(logrus.Fields{
+				"retention_days": days,
+				"deleted":        deleted,
+			}).Info("🧹 Deleted expired metric points")
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+func TestEnforceAgainstMemory(t *testing.T) {
+	store := db.NewMemory()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	old, fresh := today.AddDate(0, 0, -3).UnixMilli(), today.UnixMilli()
+
+	for i, ts := range []int64{old, fresh} {
+		event := db.Event{EventID: string(rune('a' + i)), Service: "checkout", TenantID: "acme", Status: "success", Timestamp: ts}
+		if err := store.SaveContext(event); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveAnomaly(db.Anomaly{Type: "latency", Service: "checkout", TenantID: "acme", Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := store.SaveMetricPoints([]db.MetricPoint{
+		{Service: "checkout", Name: "queue_depth", Series: "queue_depth", Timestamp: old},
+		{Service: "checkout", Name: "queue_depth", Series: "queue_depth", Timestamp: fresh},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Enforce(store, Config{Days: map[string]int{"contexts": 1, "anomalies": 1, "metric_points": 1}})
+
+	events, err := store.GetRecentEvents("acme", "checkout", db.TimeRange{}, nil, 10)
+	if err != nil || len(events) != 1 || events[0].Timestamp != fresh {
+		t.Errorf("contexts left = %v, %v; want only the fresh one", events, err)
+	}
+	anomalies, err := store.GetRecentAnomalies("acme", "checkout", db.TimeRange{}, nil, 10)
+	if err != nil || len(anomalies) != 1 || anomalies[0].Timestamp != fresh {
+		t.Errorf("anomalies left = %v, %v; want only the fresh one", anomalies, err)
+	}
+	if left, _ := store.DeleteMetricPointsBefore(today.AddDate(0, 0, 1)); left != 1 {
+		t.Errorf("%d metric points left, want 1", left)
+	}
+}
@@ -0,0 +1,83 @@
+// Package rollup keeps the 1-minute and 1-hour aggregates of contexts up
+// to date, for charts over ranges too long to scan contexts for.
+package rollup
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sujal-lgtm/Contextify/backend/services/contextify/internal/db"
+)
+
+// defaultBackfill is how far back an empty rollup table is filled when
+// its retention keeps everything
+const defaultBackfill = 7 * 24 * time.Hour
+
+// Config holds the rollup job settings
+type Config struct {
+	Interval time.Duration
+	Lateness time.Duration  // how late events may arrive and still be counted
+	Days     map[string]int // days kept per rollup table, bounding the first backfill
+}
+
+// Run rolls up new contexts now and then every cfg.Interval until ctx ends
+func Run(ctx context.Context, database db.RollupStore, cfg Config) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		Pass(database, cfg)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Pass recomputes, for each resolution, every bucket since the newest one
+// already rolled up, less cfg.Lateness so late events are counted in. An
+// empty table is filled from as far back as it keeps. Failures are logged
+// and retried on the next pass.
+func Pass(database db.RollupStore, cfg Config) {
+	now := time.Now()
+
+	for _, res := range db.Resolutions {
+		backfill := defaultBackfill
+		if days := cfg.Days[res.Table]; days > 0 {
+			backfill = time.Duration(days) * 24 * time.Hour
+		}
+		earliest := now.Add(-backfill)
+
+		from := earliest
+		latest, ok, err := database.LatestRollup(res)
+		if err != nil {
+			logrus.Errorf("Failed to read latest %s rollup: %v", res.Name, err)
+			continue
+		}
+		if ok {
+			// Future-dated events must not hold the job back
+			if latest.After(now) {
+				latest = now
+			}
+			if from = latest.Add(-cfg.Lateness); from.Before(earliest) {
+				from = earliest
+			}
+		}
+
+		start := time.Now()
+		buckets, err := database.RollUp(res, from, now)
+		if err != nil {
+			logrus.Errorf("Failed to roll up %s buckets: %v", res.Name, err)
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"resolution": res.Name,
+			"from":       from.UTC(),
+			"buckets":    buckets,
+			"took":       time.Since(start),
+		}).Debug("📊 Rolled up contexts")
+	}
+}
@@ -7,6 +7,7 @@
 package proto
 
 import (
+	schemapb "github.com/sujal-lgtm/Contextify/backend/pkg/schema/schemapb"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -109,18 +110,197 @@ func (x *PingResponse) GetReply() string {
 	return ""
 }
 
+type EventBatch struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Chosen by the client and echoed back in the batch's ack
+	BatchId       uint64            `protobuf:"varint,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Events        []*schemapb.Event `protobuf:"bytes,2,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventBatch) Reset() {
+	*x = EventBatch{}
+	mi := &file_proto_contextify_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventBatch) ProtoMessage() {}
+
+func (x *EventBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_contextify_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventBatch.ProtoReflect.Descriptor instead.
+func (*EventBatch) Descriptor() ([]byte, []int) {
+	return file_proto_contextify_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EventBatch) GetBatchId() uint64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *EventBatch) GetEvents() []*schemapb.Event {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type BatchAck struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	BatchId  uint64                 `protobuf:"varint,1,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Accepted uint32                 `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// Events that failed validation and were dropped
+	Rejected      []*EventRejection `protobuf:"bytes,3,rep,name=rejected,proto3" json:"rejected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchAck) Reset() {
+	*x = BatchAck{}
+	mi := &file_proto_contextify_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchAck) ProtoMessage() {}
+
+func (x *BatchAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_contextify_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchAck.ProtoReflect.Descriptor instead.
+func (*BatchAck) Descriptor() ([]byte, []int) {
+	return file_proto_contextify_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchAck) GetBatchId() uint64 {
+	if x != nil {
+		return x.BatchId
+	}
+	return 0
+}
+
+func (x *BatchAck) GetAccepted() uint32 {
+	if x != nil {
+		return x.Accepted
+	}
+	return 0
+}
+
+func (x *BatchAck) GetRejected() []*EventRejection {
+	if x != nil {
+		return x.Rejected
+	}
+	return nil
+}
+
+type EventRejection struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position of the event in its batch
+	Index         uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EventRejection) Reset() {
+	*x = EventRejection{}
+	mi := &file_proto_contextify_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EventRejection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EventRejection) ProtoMessage() {}
+
+func (x *EventRejection) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_contextify_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EventRejection.ProtoReflect.Descriptor instead.
+func (*EventRejection) Descriptor() ([]byte, []int) {
+	return file_proto_contextify_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EventRejection) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *EventRejection) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 var File_proto_contextify_proto protoreflect.FileDescriptor
 
 const file_proto_contextify_proto_rawDesc = "" +
 	"\n" +
 	"\x16proto/contextify.proto\x12\n" +
-	"contextify\"'\n" +
+	"contextify\x1a\x14schemapb/event.proto\"'\n" +
 	"\vPingRequest\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage\"$\n" +
 	"\fPingResponse\x12\x14\n" +
-	"\x05reply\x18\x01 \x01(\tR\x05reply2N\n" +
+	"\x05reply\x18\x01 \x01(\tR\x05reply\"\\\n" +
+	"\n" +
+	"EventBatch\x12\x19\n" +
+	"\bbatch_id\x18\x01 \x01(\x04R\abatchId\x123\n" +
+	"\x06events\x18\x02 \x03(\v2\x1b.contextify.schema.v1.EventR\x06events\"y\n" +
+	"\bBatchAck\x12\x19\n" +
+	"\bbatch_id\x18\x01 \x01(\x04R\abatchId\x12\x1a\n" +
+	"\baccepted\x18\x02 \x01(\rR\baccepted\x126\n" +
+	"\brejected\x18\x03 \x03(\v2\x1a.contextify.EventRejectionR\brejected\">\n" +
+	"\x0eEventRejection\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\rR\x05index\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason2\x90\x01\n" +
 	"\x11ContextifyService\x129\n" +
-	"\x04Ping\x12\x17.contextify.PingRequest\x1a\x18.contextify.PingResponseBDZBgithub.com/sujal-lgtm/Contextify/backend/services/contextify/protob\x06proto3"
+	"\x04Ping\x12\x17.contextify.PingRequest\x1a\x18.contextify.PingResponse\x12@\n" +
+	"\fIngestEvents\x12\x16.contextify.EventBatch\x1a\x14.contextify.BatchAck(\x010\x01BDZBgithub.com/sujal-lgtm/Contextify/backend/services/contextify/protob\x06proto3"
 
 var (
 	file_proto_contextify_proto_rawDescOnce sync.Once
@@ -134,19 +314,27 @@ func file_proto_contextify_proto_rawDescGZIP() []byte {
 	return file_proto_contextify_proto_rawDescData
 }
 
-var file_proto_contextify_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_contextify_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_proto_contextify_proto_goTypes = []any{
-	(*PingRequest)(nil),  // 0: contextify.PingRequest
-	(*PingResponse)(nil), // 1: contextify.PingResponse
+	(*PingRequest)(nil),    // 0: contextify.PingRequest
+	(*PingResponse)(nil),   // 1: contextify.PingResponse
+	(*EventBatch)(nil),     // 2: contextify.EventBatch
+	(*BatchAck)(nil),       // 3: contextify.BatchAck
+	(*EventRejection)(nil), // 4: contextify.EventRejection
+	(*schemapb.Event)(nil), // 5: contextify.schema.v1.Event
 }
 var file_proto_contextify_proto_depIdxs = []int32{
-	0, // 0: contextify.ContextifyService.Ping:input_type -> contextify.PingRequest
-	1, // 1: contextify.ContextifyService.Ping:output_type -> contextify.PingResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	5, // 0: contextify.EventBatch.events:type_name -> contextify.schema.v1.Event
+	4, // 1: contextify.BatchAck.rejected:type_name -> contextify.EventRejection
+	0, // 2: contextify.ContextifyService.Ping:input_type -> contextify.PingRequest
+	2, // 3: contextify.ContextifyService.IngestEvents:input_type -> contextify.EventBatch
+	1, // 4: contextify.ContextifyService.Ping:output_type -> contextify.PingResponse
+	3, // 5: contextify.ContextifyService.IngestEvents:output_type -> contextify.BatchAck
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_proto_contextify_proto_init() }
@@ -160,7 +348,7 @@ func file_proto_contextify_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_contextify_proto_rawDesc), len(file_proto_contextify_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
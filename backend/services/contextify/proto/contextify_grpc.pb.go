@@ -19,7 +19,8 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ContextifyService_Ping_FullMethodName = "/contextify.ContextifyService/Ping"
+	ContextifyService_Ping_FullMethodName         = "/contextify.ContextifyService/Ping"
+	ContextifyService_IngestEvents_FullMethodName = "/contextify.ContextifyService/IngestEvents"
 )
 
 // ContextifyServiceClient is the client API for ContextifyService service.
@@ -27,6 +28,13 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ContextifyServiceClient interface {
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	// IngestEvents takes batches of events over one long-lived stream and
+	// acknowledges each batch once its valid events are in Kafka. The next
+	// batch is read only after the previous one is acked, so a slow Kafka
+	// pushes back on the client through gRPC flow control. Batches not
+	// acked when the stream fails should be resent; events keep their IDs,
+	// so duplicates are dropped downstream.
+	IngestEvents(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EventBatch, BatchAck], error)
 }
 
 type contextifyServiceClient struct {
@@ -47,11 +55,31 @@ func (c *contextifyServiceClient) Ping(ctx context.Context, in *PingRequest, opt
 	return out, nil
 }
 
+func (c *contextifyServiceClient) IngestEvents(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EventBatch, BatchAck], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ContextifyService_ServiceDesc.Streams[0], ContextifyService_IngestEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EventBatch, BatchAck]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ContextifyService_IngestEventsClient = grpc.BidiStreamingClient[EventBatch, BatchAck]
+
 // ContextifyServiceServer is the server API for ContextifyService service.
 // All implementations must embed UnimplementedContextifyServiceServer
 // for forward compatibility.
 type ContextifyServiceServer interface {
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	// IngestEvents takes batches of events over one long-lived stream and
+	// acknowledges each batch once its valid events are in Kafka. The next
+	// batch is read only after the previous one is acked, so a slow Kafka
+	// pushes back on the client through gRPC flow control. Batches not
+	// acked when the stream fails should be resent; events keep their IDs,
+	// so duplicates are dropped downstream.
+	IngestEvents(grpc.BidiStreamingServer[EventBatch, BatchAck]) error
 	mustEmbedUnimplementedContextifyServiceServer()
 }
 
@@ -65,6 +93,9 @@ type UnimplementedContextifyServiceServer struct{}
 func (UnimplementedContextifyServiceServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
+func (UnimplementedContextifyServiceServer) IngestEvents(grpc.BidiStreamingServer[EventBatch, BatchAck]) error {
+	return status.Errorf(codes.Unimplemented, "method IngestEvents not implemented")
+}
 func (UnimplementedContextifyServiceServer) mustEmbedUnimplementedContextifyServiceServer() {}
 func (UnimplementedContextifyServiceServer) testEmbeddedByValue()                           {}
 
@@ -104,6 +135,13 @@ func _ContextifyService_Ping_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ContextifyService_IngestEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ContextifyServiceServer).IngestEvents(&grpc.GenericServerStream[EventBatch, BatchAck]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ContextifyService_IngestEventsServer = grpc.BidiStreamingServer[EventBatch, BatchAck]
+
 // ContextifyService_ServiceDesc is the grpc.ServiceDesc for ContextifyService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -116,6 +154,13 @@ var ContextifyService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ContextifyService_Ping_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "IngestEvents",
+			Handler:       _ContextifyService_IngestEvents_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "proto/contextify.proto",
 }
@@ -1 +0,0 @@
-ALTER TABLE anomalies ADD COLUMN trace_id TEXT;
\ No newline at end of file